@@ -1,8 +1,10 @@
 package config
 
 import (
+	"fmt"
 	"slices"
 	"sync"
+	"sync/atomic"
 )
 
 type configItem struct {
@@ -11,40 +13,117 @@ type configItem struct {
 	writable bool
 }
 
-// Collection of config layers with priorities
+// Collection of config layers with priorities. The layer list is replaced on
+// every change, so reading the config needs no locking of the config itself,
+// only of the layers
 type Config struct {
-	mx    sync.Mutex
-	items []configItem
+	mx            sync.Mutex // serializes changes of the layer list and subscriptions
+	items         atomic.Pointer[[]configItem]
+	cache         atomic.Pointer[sync.Map]
+	interpolation atomic.Bool
+	caching       bool // caching is enabled, guarded by mx
+	subs          []*subscriber
+	observers     map[*Layer]*layerObserver
 }
 
 // Create a config with no layers
 func NewConfig() *Config {
-	return &Config{
-		items: []configItem{},
+	return &Config{}
+}
+
+// Get the current layer list, must not be modified
+func (c *Config) loadItems() []configItem {
+	if items := c.items.Load(); items != nil {
+		return *items
 	}
+	return nil
 }
 
-func (c *Config) addLayerImpl(layer *Layer, prio int, writable bool) {
+// Enable or disable caching the effective values. The cache speeds up reading
+// configs with many layers, it is invalidated whenever a layer changes or
+// layers are added or removed
+func (c *Config) SetCaching(enabled bool) {
 	// lock layer list
 	c.mx.Lock()
 	defer c.mx.Unlock()
 
+	// observe the layers before creating the cache, so that no change is missed
+	c.caching = enabled
+	c.updateObservers()
+	if enabled {
+		c.cache.CompareAndSwap(nil, &sync.Map{})
+	} else {
+		c.cache.Store(nil)
+	}
+}
+
+// Observe the layers only while the config has subscribers or caching, so that
+// a config without them is not referenced by its layers. Must be called with
+// the layer list locked
+func (c *Config) updateObservers() {
+	observed := map[*Layer]bool{}
+	if len(c.subs) > 0 || c.caching {
+		for _, item := range c.loadItems() {
+			observed[item.layer] = true
+		}
+	}
+	for layer, o := range c.observers {
+		if !observed[layer] {
+			layer.unobserve(o)
+			delete(c.observers, layer)
+		}
+	}
+	for layer := range observed {
+		if c.observers[layer] == nil {
+			if c.observers == nil {
+				c.observers = map[*Layer]*layerObserver{}
+			}
+			c.observers[layer] = layer.observe(c.layerChanged)
+		}
+	}
+}
+
+// Drop all cached values if caching is enabled
+func (c *Config) invalidateCache() {
+	for {
+		cache := c.cache.Load()
+		if cache == nil || c.cache.CompareAndSwap(cache, &sync.Map{}) {
+			return
+		}
+	}
+}
+
+func (c *Config) addLayerImpl(layer *Layer, prio int, writable bool) {
+	// lock layer list
+	c.mx.Lock()
+	before := c.effectiveValuesOf(layer)
+
 	// find slot for layer
+	items := c.loadItems()
 	i := 0
-	max := len(c.items)
+	max := len(items)
 	for i < max {
-		if c.items[i].prio < prio {
+		if items[i].prio < prio {
 			break
 		}
 		i++
 	}
 
-	// insert layer to list
-	c.items = slices.Insert(c.items, i, configItem{
+	// insert layer to a copy of the list
+	items = slices.Insert(slices.Clone(items), i, configItem{
 		layer,
 		prio,
 		writable,
 	})
+	c.items.Store(&items)
+	c.invalidateCache()
+	c.updateObservers()
+
+	// notify subscribers after unlocking
+	events := c.effectiveChanges(layer, before)
+	subs := c.subs
+	c.mx.Unlock()
+	dispatchEvents(events, subs)
 }
 
 // Add a read only layer to the config
@@ -62,31 +141,39 @@ func (c *Config) AddWritableLayer(layer *Layer, prio int) {
 func (c *Config) RemoveLayer(layer *Layer) bool {
 	// lock layer list
 	c.mx.Lock()
-	defer c.mx.Unlock()
 
 	// find layer
-	idx := slices.IndexFunc(c.items, func(item configItem) bool {
+	isLayer := func(item configItem) bool {
 		return item.layer == layer
-	})
-
-	// delete layer if found
+	}
+	items := c.loadItems()
+	idx := slices.IndexFunc(items, isLayer)
 	if idx < 0 {
+		c.mx.Unlock()
 		return false
-	} else {
-		c.items = slices.Delete(c.items, idx, idx+1)
-		return true
 	}
+
+	// delete layer from a copy of the list
+	before := c.effectiveValuesOf(layer)
+	items = slices.Delete(slices.Clone(items), idx, idx+1)
+	c.items.Store(&items)
+	c.invalidateCache()
+	c.updateObservers()
+
+	// notify subscribers after unlocking
+	events := c.effectiveChanges(layer, before)
+	subs := c.subs
+	c.mx.Unlock()
+	dispatchEvents(events, subs)
+	return true
 }
 
 // Get a list of the layers the config currently contains
 func (c *Config) Layers() []*Layer {
-	// lock layer list
-	c.mx.Lock()
-	defer c.mx.Unlock()
-
 	// create return list and fill it
-	ret := make([]*Layer, 0, len(c.items))
-	for _, item := range c.items {
+	items := c.loadItems()
+	ret := make([]*Layer, 0, len(items))
+	for _, item := range items {
 		ret = append(ret, item.layer)
 	}
 	return ret
@@ -94,66 +181,151 @@ func (c *Config) Layers() []*Layer {
 
 // Test if the config is writable
 func (c *Config) IsWritable() bool {
-	// lock layer list
-	c.mx.Lock()
-	defer c.mx.Unlock()
-
-	// find writable layer
-	for _, item := range c.items {
-		if item.writable && item.layer.IsWritable() {
-			return true
-		}
-	}
-	return false
+	return c.writableLayer() != nil
 }
 
-// Get raw string value for the given key
+// Get string value for the given key. If interpolation is enabled, the
+// references in the value are expanded, and the key is reported not found if
+// they cannot be resolved, see Config.SetInterpolation
 func (c *Config) GetString(key string) (string, bool) {
-	// lock layer list
-	c.mx.Lock()
-	defer c.mx.Unlock()
+	ret, found, err := c.getExpanded(key)
+	return ret, found && err == nil
+}
 
-	// find in all layers
-	for _, item := range c.items {
-		s, ok := item.layer.GetString(key)
+// Value of a key as supplied by one of the layers of a config
+type LayerValue struct {
+	Layer    *Layer // layer defining the value
+	Priority int    // priority of the layer in the config
+	Value    string // raw string value
+	Deleted  bool   // the layer has a tombstone for the key instead of a value
+}
+
+// Get raw string value for the given key along with the layer that supplied
+// it. On fail, the second return value is false
+func (c *Config) Lookup(key string) (LayerValue, bool) {
+	// find in all layers, stop at a tombstone
+	for _, item := range c.loadItems() {
+		s, ok, deleted := item.layer.lookup(key)
 		if ok {
-			return s, true
+			return LayerValue{item.layer, item.prio, s, false}, true
+		}
+		if deleted {
+			break
 		}
 	}
 
 	// not found
-	return "", false
+	return LayerValue{}, false
 }
 
-// Set raw string value for the given key
+// List every layer defining the given key with its value, in priority order.
+// Layers having a tombstone for the key are also listed with Deleted set, they
+// hide the values of the layers listed after them. The first item is the
+// effective value, unless it is deleted
+func (c *Config) Explain(key string) []LayerValue {
+	// collect from all layers
+	ret := []LayerValue{}
+	for _, item := range c.loadItems() {
+		s, ok, deleted := item.layer.lookup(key)
+		if ok || deleted {
+			ret = append(ret, LayerValue{item.layer, item.prio, s, deleted})
+		}
+	}
+	return ret
+}
+
+// Get the layer that supplies the value for the given key, nil if not found
+func (c *Config) layerOf(key string) *Layer {
+	lv, _ := c.Lookup(key)
+	return lv.Layer
+}
+
+// Set raw string value for the given key in the top writable layer, panics if
+// the config is not writable
 func (c *Config) SetString(key, value string) {
-	// lock layer list
-	c.mx.Lock()
-	defer c.mx.Unlock()
+	if err := c.TrySetString(key, value); err != nil {
+		panic("config is not writable")
+	}
+}
 
+// Set raw string value for the given key in the top writable layer, returns an
+// error wrapping ErrReadOnly if the config is not writable
+func (c *Config) TrySetString(key, value string) error {
 	// find writable layer
-	for _, item := range c.items {
+	layer := c.writableLayer()
+	if layer == nil {
+		// no writable layer, set not possible
+		return errConfigReadOnly
+	}
+	return layer.TrySetString(key, value)
+}
+
+var errConfigReadOnly = fmt.Errorf("%w: config has no writable layer", ErrReadOnly)
+
+// Get the top writable layer, nil if there is none
+func (c *Config) writableLayer() *Layer {
+	for _, item := range c.loadItems() {
 		if item.writable && item.layer.IsWritable() {
-			item.layer.SetString(key, value)
-			return
+			return item.layer
 		}
 	}
+	return nil
+}
+
+// Delete value for the given key. A tombstone is left in the top writable
+// layer, which hides the key in the lower priority layers. Panics if the
+// config is not writable
+func (c *Config) DeleteValue(key string) {
+	if err := c.TryDeleteValue(key); err != nil {
+		panic("config is not writable")
+	}
+}
+
+// Delete value for the given key like DeleteValue, returns an error wrapping
+// ErrReadOnly if the config is not writable
+func (c *Config) TryDeleteValue(key string) error {
+	layer := c.writableLayer()
+	if layer == nil {
+		return errConfigReadOnly
+	}
+	return layer.tryDeleteWithTombstone(key)
+}
 
-	// no writable layer, set not possible
-	panic("config is not writable")
+// Remove both the value and the tombstone of the given key from the top
+// writable layer, so that the lower priority layers supply the value again.
+// Panics if the config is not writable
+func (c *Config) ResetValue(key string) {
+	if err := c.TryResetValue(key); err != nil {
+		panic("config is not writable")
+	}
 }
 
-// Only supplied for interface compatibility
-func (c *Config) DeleteValue(key string) {} // not implemented in Config
+// Remove the value and the tombstone of the given key like ResetValue,
+// returns an error wrapping ErrReadOnly if the config is not writable
+func (c *Config) TryResetValue(key string) error {
+	layer := c.writableLayer()
+	if layer == nil {
+		return errConfigReadOnly
+	}
+	return layer.TryDeleteValue(key)
+}
 
-// List keys, see Viewable for details
+// List keys, see Viewable for details. Keys deleted by a tombstone in a higher
+// priority layer are left out
 func (c *Config) ListKeys(prefix string, out *KeyList, direct bool) {
-	// lock layer list
-	c.mx.Lock()
-	defer c.mx.Unlock()
+	// ensure trailing dot
+	prefix = normalizePrefix(prefix)
 
-	// list in all layers
-	for _, item := range c.items {
-		item.layer.ListKeys(prefix, out, direct)
+	// list in all layers, collect tombstones of the layers already visited
+	deleted := map[string]bool{}
+	for _, item := range c.loadItems() {
+		var keys KeyList
+		item.layer.ListKeys(prefix, &keys, false)
+		for k := range keys.v {
+			if !deleted[prefix+k] {
+				out.addKey(directKey(k, direct))
+			}
+		}
+		item.layer.collectTombstones(deleted)
 	}
 }
@@ -1,14 +1,16 @@
 package config
 
 import (
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
 func TestConfig(t *testing.T) {
 	conf := NewConfig()
-	conf.items = []configItem{}
+	conf.items.Store(&[]configItem{})
 
 	// create and add a layer
 	l2 := NewLayer("l2")
@@ -120,3 +122,256 @@ func TestConfig(t *testing.T) {
 	assert.False(t, ok)
 	assert.Equal(t, "", s)
 }
+
+func TestConfigLookupExplain(t *testing.T) {
+	defaults := NewLayer("defaults")
+	defaults.SetString("http.port", "8080")
+	defaults.SetString("http.host", "localhost")
+	ini := NewLayer("ini")
+	ini.SetString("http.port", "9090")
+	env := NewLayer("env")
+	env.SetString("http.port", "80")
+
+	conf := NewConfig()
+	conf.AddLayer(ini, 10)
+	conf.AddLayer(defaults, 0)
+	conf.AddLayer(env, 100)
+
+	// lookup
+	lv, ok := conf.Lookup("http.port")
+	assert.True(t, ok)
+	assert.Equal(t, LayerValue{env, 100, "80", false}, lv)
+	assert.Equal(t, "env", lv.Layer.Name())
+
+	lv, ok = conf.Lookup("http.host")
+	assert.True(t, ok)
+	assert.Equal(t, LayerValue{defaults, 0, "localhost", false}, lv)
+
+	lv, ok = conf.Lookup("missing")
+	assert.False(t, ok)
+	assert.Nil(t, lv.Layer)
+
+	// explain
+	assert.Equal(t, []LayerValue{
+		{env, 100, "80", false},
+		{ini, 10, "9090", false},
+		{defaults, 0, "8080", false},
+	}, conf.Explain("http.port"))
+	assert.Equal(t, []LayerValue{{defaults, 0, "localhost", false}}, conf.Explain("http.host"))
+	assert.Equal(t, []LayerValue{}, conf.Explain("missing"))
+}
+
+func TestConfigDeleteValue(t *testing.T) {
+	defaults := NewLayer("defaults")
+	defaults.SetString("http.port", "8080")
+	defaults.SetString("http.host", "localhost")
+	defaults.SetString("http.tls.cert", "cert.pem")
+	overrides := NewLayer("overrides")
+
+	conf := NewConfig()
+	conf.AddLayer(defaults, 0)
+
+	// not writable
+	assert.Panics(t, func() {
+		conf.DeleteValue("http.port")
+	})
+	assert.Panics(t, func() {
+		conf.ResetValue("http.port")
+	})
+	conf.AddWritableLayer(overrides, 10)
+
+	// override, then disable the default through a view
+	view := NewView(conf, "http")
+	view.SetString("port", "9090")
+	view.DeleteValue("port")
+	view.DeleteValue("tls.cert")
+	_, ok := view.GetString("port")
+	assert.False(t, ok)
+	_, ok = conf.Lookup("http.port")
+	assert.False(t, ok)
+	assert.True(t, overrides.IsDeleted("http.port"))
+	assert.False(t, defaults.IsDeleted("http.port"))
+	assert.Equal(t, []LayerValue{
+		{overrides, 10, "", true},
+		{defaults, 0, "8080", false},
+	}, conf.Explain("http.port"))
+
+	// deleted keys are not listed
+	assert.ElementsMatch(t, listKeys(conf, "", false), []string{"http.host"})
+	assert.ElementsMatch(t, listKeys(conf, "http", true), []string{"host"})
+
+	// setting the value again removes the tombstone
+	view.SetString("port", "7070")
+	s, ok := view.GetString("port")
+	assert.True(t, ok)
+	assert.Equal(t, "7070", s)
+	assert.False(t, overrides.IsDeleted("http.port"))
+
+	// unset the override, default is visible again
+	conf.ResetValue("http.port")
+	conf.ResetValue("http.tls.cert")
+	s, ok = view.GetString("port")
+	assert.True(t, ok)
+	assert.Equal(t, "8080", s)
+	assert.ElementsMatch(t, listKeys(view, "", true), []string{"port", "host", "tls"})
+
+	// deleting directly from the layer also removes the tombstone
+	conf.DeleteValue("http.host")
+	_, ok = view.GetString("host")
+	assert.False(t, ok)
+	overrides.DeleteValue("http.host")
+	s, ok = view.GetString("host")
+	assert.True(t, ok)
+	assert.Equal(t, "localhost", s)
+
+	// tombstones are cleared with the layer
+	conf.DeleteValue("http.host")
+	overrides.Clear()
+	assert.False(t, overrides.IsDeleted("http.host"))
+	_, ok = view.GetString("host")
+	assert.True(t, ok)
+}
+
+func TestConfigTryWrite(t *testing.T) {
+	defaults := NewLayer("defaults")
+	defaults.SetString("port", "8080")
+	conf := NewConfig()
+	conf.AddLayer(defaults, 0)
+
+	// no writable layer
+	assert.ErrorIs(t, conf.TrySetString("port", "9090"), ErrReadOnly)
+	assert.ErrorIs(t, conf.TryDeleteValue("port"), ErrReadOnly)
+	assert.ErrorIs(t, conf.TryResetValue("port"), ErrReadOnly)
+
+	// writable layer
+	overrides := NewLayer("overrides")
+	conf.AddWritableLayer(overrides, 10)
+	assert.Nil(t, conf.TrySetString("port", "9090"))
+	s, _ := conf.GetString("port")
+	assert.Equal(t, "9090", s)
+	assert.Nil(t, conf.TryDeleteValue("port"))
+	_, ok := conf.GetString("port")
+	assert.False(t, ok)
+	assert.Nil(t, conf.TryResetValue("port"))
+	s, _ = conf.GetString("port")
+	assert.Equal(t, "8080", s)
+
+	// locked writable layer
+	overrides.LockReadOnly()
+	assert.ErrorIs(t, conf.TrySetString("port", "9090"), ErrReadOnly)
+}
+
+func TestConfigCaching(t *testing.T) {
+	defaults := NewLayer("defaults")
+	defaults.SetString("a", "1")
+	overrides := NewLayer("overrides")
+
+	conf := NewConfig()
+	conf.SetCaching(true)
+	conf.AddLayer(defaults, 0)
+
+	// cached values and misses
+	s, ok := conf.GetString("a")
+	assert.True(t, ok)
+	assert.Equal(t, "1", s)
+	_, ok = conf.GetString("b")
+	assert.False(t, ok)
+
+	// layer changes invalidate the cache
+	defaults.SetString("a", "2")
+	defaults.SetString("b", "3")
+	s, _ = conf.GetString("a")
+	assert.Equal(t, "2", s)
+	s, _ = conf.GetString("b")
+	assert.Equal(t, "3", s)
+
+	// adding and removing layers invalidate the cache
+	overrides.SetString("a", "4")
+	conf.AddWritableLayer(overrides, 10)
+	s, _ = conf.GetString("a")
+	assert.Equal(t, "4", s)
+	conf.DeleteValue("b")
+	_, ok = conf.GetString("b")
+	assert.False(t, ok)
+	conf.RemoveLayer(overrides)
+	s, _ = conf.GetString("a")
+	assert.Equal(t, "2", s)
+
+	// disable caching
+	conf.SetCaching(false)
+	assert.Nil(t, conf.cache.Load())
+	defaults.SetString("a", "5")
+	s, _ = conf.GetString("a")
+	assert.Equal(t, "5", s)
+}
+
+func newBenchmarkConfig(caching bool) *Config {
+	conf := NewConfig()
+	conf.SetCaching(caching)
+	for i := 0; i < 5; i++ {
+		l := NewLayer(fmt.Sprint("layer", i))
+		for j := 0; j < 100; j++ {
+			l.SetString(fmt.Sprintf("section%d.key%d", i, j), "value")
+		}
+		conf.AddWritableLayer(l, i)
+	}
+	return conf
+}
+
+func benchmarkConfigGetString(b *testing.B, caching bool, writes bool) {
+	conf := newBenchmarkConfig(caching)
+	if writes {
+		// concurrent writer invalidating the cache
+		stop := make(chan struct{})
+		defer close(stop)
+		go func() {
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					conf.SetString("written", "value")
+					time.Sleep(time.Millisecond)
+				}
+			}
+		}()
+	}
+	keys := make([]string, 100)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("section0.key%d", i)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			conf.GetString(keys[i%len(keys)])
+			i++
+		}
+	})
+}
+
+func BenchmarkConfigGetString(b *testing.B) {
+	benchmarkConfigGetString(b, false, false)
+}
+
+func BenchmarkConfigGetStringCached(b *testing.B) {
+	benchmarkConfigGetString(b, true, false)
+}
+
+func BenchmarkConfigGetStringWithWrites(b *testing.B) {
+	benchmarkConfigGetString(b, false, true)
+}
+
+func BenchmarkConfigGetStringCachedWithWrites(b *testing.B) {
+	benchmarkConfigGetString(b, true, true)
+}
+
+func BenchmarkLayerGetString(b *testing.B) {
+	l := NewLayer("layer")
+	l.SetString("key", "value")
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			l.GetString("key")
+		}
+	})
+}
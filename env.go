@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Options for loading config values from environment variables
+type EnvOptions struct {
+	// Only variables starting with the prefix are loaded, e.g. "MYAPP_". The
+	// prefix is stripped from the keys
+	Prefix string
+	// Separator of the key parts in variable names, "__" if empty. With the
+	// default, MYAPP_HTTP__SERVER__PORT is mapped to http.server.port
+	Separator string
+	// Keep the case of variable names, otherwise keys are converted to lower case
+	PreserveCase bool
+	// Environment in "NAME=value" form, os.Environ() is used if nil
+	Environ []string
+}
+
+// Map an environment variable name to a config key, return false if the
+// variable does not belong to the config
+func (opts *EnvOptions) keyOf(name string) (string, bool) {
+	name, ok := strings.CutPrefix(name, opts.Prefix)
+	if !ok || name == "" {
+		return "", false
+	}
+	sep := opts.Separator
+	if sep == "" {
+		sep = "__"
+	}
+	parts := strings.Split(name, sep)
+	for _, part := range parts {
+		if part == "" {
+			return "", false
+		}
+	}
+	key := strings.Join(parts, ".")
+	if !opts.PreserveCase {
+		key = strings.ToLower(key)
+	}
+	return key, true
+}
+
+// Load config values from environment variables and store them in viewable
+// (must be writable)
+func LoadEnv(viewable Viewable, opts EnvOptions) error {
+	// check if viewable is writable
+	if !viewable.IsWritable() {
+		return fmt.Errorf("cannot load environment: %w", ErrReadOnly)
+	}
+
+	environ := opts.Environ
+	if environ == nil {
+		environ = os.Environ()
+	}
+	for _, kv := range environ {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok {
+			continue
+		}
+		if key, ok := opts.keyOf(name); ok {
+			viewable.SetString(key, value)
+		}
+	}
+	return nil
+}
+
+// Create a read-only layer with the given name, loaded from environment
+// variables. Add it with a high priority to let the environment override other
+// layers
+func NewEnvLayer(name string, opts EnvOptions) *Layer {
+	l := NewLayer(name)
+	LoadEnv(l, opts) // the new layer is writable, cannot fail
+	l.LockReadOnly()
+	return l
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadEnv(t *testing.T) {
+	environ := []string{
+		"MYAPP_HTTP__SERVER__PORT=9090",
+		"MYAPP_MAX_CONNS=10",
+		"MYAPP_EMPTY=",
+		"MYAPP_WITH_EQ=a=b",
+		"MYAPP_=ignored",
+		"MYAPP_BAD____KEY=ignored",
+		"OTHER_HTTP__SERVER__PORT=1",
+		"noequalsign",
+	}
+
+	l := NewLayer("env")
+	err := LoadEnv(l, EnvOptions{Prefix: "MYAPP_", Environ: environ})
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, listKeys(l, "", false), []string{
+		"http.server.port",
+		"max_conns",
+		"empty",
+		"with_eq",
+	})
+
+	i, ok := NewView(l, "http.server").GetInt("port")
+	assert.True(t, ok)
+	assert.EqualValues(t, 9090, i)
+
+	s, ok := l.GetString("with_eq")
+	assert.True(t, ok)
+	assert.Equal(t, "a=b", s)
+
+	s, ok = l.GetString("empty")
+	assert.True(t, ok)
+	assert.Equal(t, "", s)
+
+	// custom separator, keep case
+	l = NewLayer("env")
+	err = LoadEnv(l, EnvOptions{Prefix: "MYAPP_", Separator: "_", PreserveCase: true, Environ: environ})
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, listKeys(l, "", false), []string{
+		"MAX.CONNS",
+		"EMPTY",
+		"WITH.EQ",
+	})
+
+	// try to load to a read-only target
+	err = LoadEnv(NewEmptyView(), EnvOptions{Environ: environ})
+	assert.ErrorIs(t, err, ErrReadOnly)
+}
+
+func TestEnvLayer(t *testing.T) {
+	t.Setenv("GOCONFIGTEST_HTTP__PORT", "9090")
+
+	// real process environment is used
+	env := NewEnvLayer("env", EnvOptions{Prefix: "GOCONFIGTEST_"})
+	assert.Equal(t, "env", env.Name())
+	assert.False(t, env.IsWritable())
+
+	// environment overrides the defaults
+	defaults := NewLayer("defaults")
+	defaults.SetString("http.port", "8080")
+	defaults.SetString("http.host", "localhost")
+	conf := NewConfig()
+	conf.AddLayer(defaults, 0)
+	conf.AddLayer(env, 100)
+
+	s, ok := conf.GetString("http.port")
+	assert.True(t, ok)
+	assert.Equal(t, "9090", s)
+
+	s, ok = conf.GetString("http.host")
+	assert.True(t, ok)
+	assert.Equal(t, "localhost", s)
+}
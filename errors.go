@@ -0,0 +1,9 @@
+package config
+
+import "errors"
+
+// Error reported when a key has no value
+var ErrNotFound = errors.New("config: key not found")
+
+// Error reported when writing to a read-only layer, config or view
+var ErrReadOnly = errors.New("config: not writable")
@@ -0,0 +1,221 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Function loading config values from a reader into a writable viewable, like
+// LoadJson, LoadYaml or LoadToml
+type FileLoader func(viewable Viewable, reader io.Reader) error
+
+// Options of a file layer
+type FileLayerOptions struct {
+	// Function loading the file. If nil, it is chosen by the file extension:
+	// .json, .yaml, .yml and .toml files are loaded with the matching loader,
+	// all other files as INI
+	Loader FileLoader
+	// Options of loading INI files, used if Loader is nil. FileName defaults
+	// to the path of the file
+	Ini IniOptions
+	// Interval of checking the file for changes. If zero, the file is only
+	// reloaded when calling FileLayer.Reload
+	Interval time.Duration
+	// Called when reloading the file fails in the background. The layer keeps
+	// the last successfully loaded values. An error is reported once, and again
+	// only if the file changes, or if the file cannot be accessed and the error
+	// changes. An error is reported only if it persists for one more interval,
+	// so that reading a file while it is being written is not reported
+	OnError func(err error)
+}
+
+// Granularity of file modification times assumed when checking for changes,
+// that of the coarsest common filesystems
+const fileTimeGranularity = 2 * time.Second
+
+// Read-only layer loaded from a file, reloaded when the file changes. The
+// values are replaced at once on reload, so readers never see a partially
+// loaded layer. Use the embedded Layer to add it to a config
+type FileLayer struct {
+	*Layer
+	path   string
+	opts   FileLayerOptions
+	mx     sync.Mutex
+	stat   os.FileInfo // nil if the file could not be accessed
+	hash   [sha256.Size]byte
+	read   time.Time // time of the last read of the file
+	err    error     // error of accessing or loading the current file
+	loads  uint64    // number of successful loads
+	swaps  uint64    // number of the last load swapped in, guarded by the layer mutex
+	stop   chan struct{}
+	done   chan struct{}
+	closed sync.Once
+}
+
+// Get the loader of the file by its extension, INI files are loaded with the
+// given options
+func fileLoaderOf(path string, ini IniOptions) FileLoader {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return LoadJson
+	case ".yaml", ".yml":
+		return LoadYaml
+	case ".toml":
+		return LoadToml
+	default:
+		if ini.FileName == "" {
+			ini.FileName = path
+		}
+		return func(viewable Viewable, reader io.Reader) error {
+			return LoadIniWithOptions(viewable, reader, ini)
+		}
+	}
+}
+
+// Create a layer with the given name, loaded from the file at path. Returns
+// error if the first load fails. If opts.Interval is not zero, the file is
+// checked for changes in the background until Close is called
+func NewFileLayer(name, path string, opts FileLayerOptions) (*FileLayer, error) {
+	if opts.Loader == nil {
+		opts.Loader = fileLoaderOf(path, opts.Ini)
+	}
+	fl := &FileLayer{
+		Layer: NewLayer(name),
+		path:  path,
+		opts:  opts,
+	}
+	fl.LockReadOnly()
+	if err := fl.Reload(); err != nil {
+		return nil, err
+	}
+
+	// start polling
+	if opts.Interval > 0 {
+		fl.stop = make(chan struct{})
+		fl.done = make(chan struct{})
+		go fl.poll()
+	}
+	return fl, nil
+}
+
+// Get the path of the file
+func (fl *FileLayer) Path() string {
+	return fl.path
+}
+
+// Check the file and reload it if changed. The file is read only if its
+// modification time or size changed, or its modification time is too close to
+// the last read to tell a later change apart, and loaded only if its content
+// hash changed. On error the layer keeps its values, and the error is returned
+// until the file changes. The subscribers of the layer may call Reload
+func (fl *FileLayer) Reload() error {
+	values, load, err := fl.load()
+	if err != nil || values == nil {
+		return err
+	}
+
+	// swap the values with the file mutex unlocked, so that the subscribers can
+	// call Reload. Values of a load finishing after a later one are dropped
+	return fl.modify(false, func() []layerChange {
+		if load < fl.swaps {
+			return nil
+		}
+		fl.swaps = load
+		return fl.swapValues(values, map[string]bool{})
+	})
+}
+
+// Check the file and load it if changed, see Reload. Returns the loaded values
+// and the number of the load, or nil values if the file is unchanged
+func (fl *FileLayer) load() (map[string]string, uint64, error) {
+	// lock mutex
+	fl.mx.Lock()
+	defer fl.mx.Unlock()
+
+	// check modification time and size
+	stat, err := os.Stat(fl.path)
+	if err != nil {
+		return nil, 0, fl.accessError(err)
+	}
+	if fl.stat != nil && stat.ModTime().Equal(fl.stat.ModTime()) && stat.Size() == fl.stat.Size() &&
+		stat.ModTime().Before(fl.read.Add(-fileTimeGranularity)) {
+		return nil, 0, fl.err
+	}
+
+	// check content
+	read := time.Now()
+	data, err := os.ReadFile(fl.path)
+	if err != nil {
+		return nil, 0, fl.accessError(err)
+	}
+	hash := sha256.Sum256(data)
+	if fl.stat != nil && hash == fl.hash {
+		fl.stat, fl.read = stat, read
+		return nil, 0, fl.err
+	}
+
+	// load to a new layer. The file state and the error are remembered even if
+	// loading fails, so that the file is not loaded again until it changes
+	fl.stat, fl.hash, fl.read = stat, hash, read
+	tmp := NewLayer(fl.Name())
+	fl.err = fl.opts.Loader(tmp, bytes.NewReader(data))
+	if fl.err != nil {
+		return nil, 0, fl.err
+	}
+	fl.loads++
+	return tmp.values, fl.loads, nil
+}
+
+// Remember an error of accessing the file, so that the file is loaded again
+// once it is accessible. The previous error is kept while the file fails the
+// same way, so that it is reported only once. Must be called with the mutex
+// locked
+func (fl *FileLayer) accessError(err error) error {
+	if fl.stat != nil || fl.err == nil || fl.err.Error() != err.Error() {
+		fl.err = err
+	}
+	fl.stat = nil
+	return fl.err
+}
+
+func (fl *FileLayer) poll() {
+	defer close(fl.done)
+	ticker := time.NewTicker(fl.opts.Interval)
+	defer ticker.Stop()
+	var reported error // last reported error, returned until the file changes
+	var pending error  // error of the previous check, reported if it persists
+	for {
+		select {
+		case <-fl.stop:
+			return
+		case <-ticker.C:
+			err := fl.Reload()
+			if err != nil && err != reported && err == pending {
+				if fl.opts.OnError != nil {
+					fl.opts.OnError(err)
+				}
+				reported = err
+			} else if err == nil {
+				reported = nil
+			}
+			pending = err
+		}
+	}
+}
+
+// Stop checking the file for changes. The layer keeps its values
+func (fl *FileLayer) Close() {
+	if fl.stop == nil {
+		return
+	}
+	fl.closed.Do(func() {
+		close(fl.stop)
+		<-fl.done
+	})
+}
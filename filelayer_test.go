@@ -0,0 +1,219 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileLayer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.ini")
+	assert.Nil(t, os.WriteFile(path, []byte("[http]\nport=8080\nhost=localhost\n"), 0o644))
+
+	fl, err := NewFileLayer("file", path, FileLayerOptions{})
+	assert.Nil(t, err)
+	defer fl.Close()
+	assert.Equal(t, path, fl.Path())
+	assert.False(t, fl.IsWritable())
+	s, ok := fl.GetString("http.port")
+	assert.True(t, ok)
+	assert.Equal(t, "8080", s)
+
+	// use in config, subscribe to changes
+	conf := NewConfig()
+	conf.AddLayer(fl.Layer, 10)
+	var events []ChangeEvent
+	conf.Subscribe("", collectEvents(&events))
+
+	// nothing changed
+	assert.Nil(t, fl.Reload())
+	assert.Nil(t, events)
+
+	// change the file
+	assert.Nil(t, os.WriteFile(path, []byte("[http]\nport=9090\ntimeout=5s\n"), 0o644))
+	assert.Nil(t, fl.Reload())
+	assert.ElementsMatch(t, listKeys(conf, "", false), []string{"http.port", "http.timeout"})
+	assert.ElementsMatch(t, []ChangeEvent{
+		{"http.port", "8080", "9090", true, true, fl.Layer},
+		{"http.host", "localhost", "", true, false, fl.Layer},
+		{"http.timeout", "", "5s", false, true, fl.Layer},
+	}, events)
+
+	// missing file keeps the values
+	assert.Nil(t, os.Remove(path))
+	assert.NotNil(t, fl.Reload())
+	s, _ = conf.GetString("http.port")
+	assert.Equal(t, "9090", s)
+
+	// missing file at start
+	_, err = NewFileLayer("file", path, FileLayerOptions{})
+	assert.NotNil(t, err)
+}
+
+func TestFileLayerSameSizeAndTime(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.ini")
+	assert.Nil(t, os.WriteFile(path, []byte("level=info\n"), 0o644))
+	fl, err := NewFileLayer("file", path, FileLayerOptions{})
+	assert.Nil(t, err)
+	stat, err := os.Stat(path)
+	assert.Nil(t, err)
+
+	// change within the same modification time tick is detected by content
+	assert.Nil(t, os.WriteFile(path, []byte("level=warn\n"), 0o644))
+	assert.Nil(t, os.Chtimes(path, stat.ModTime(), stat.ModTime()))
+	assert.Nil(t, fl.Reload())
+	s, _ := fl.GetString("level")
+	assert.Equal(t, "warn", s)
+}
+
+func TestFileLayerLoaders(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.json": `{"a": {"b": 1}}`,
+		"a.yaml": "a:\n  b: 1\n",
+		"a.yml":  "a:\n  b: 1\n",
+		"a.toml": "[a]\nb = 1\n",
+		"a.conf": "[a]\nb=1\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		assert.Nil(t, os.WriteFile(path, []byte(content), 0o644))
+		fl, err := NewFileLayer(name, path, FileLayerOptions{})
+		assert.Nil(t, err, name)
+		s, _ := fl.GetString("a.b")
+		assert.Equal(t, "1", s, name)
+	}
+
+	// explicit loader
+	path := filepath.Join(dir, "a.conf")
+	assert.Nil(t, os.WriteFile(path, []byte(`{"x": "y"}`), 0o644))
+	fl, err := NewFileLayer("json", path, FileLayerOptions{Loader: LoadJson})
+	assert.Nil(t, err)
+	s, _ := fl.GetString("x")
+	assert.Equal(t, "y", s)
+
+	// load error keeps the last good values
+	assert.Nil(t, os.WriteFile(path, []byte(`{"x": `), 0o644))
+	err1 := fl.Reload()
+	assert.NotNil(t, err1)
+	s, _ = fl.GetString("x")
+	assert.Equal(t, "y", s)
+
+	// error is returned until the file changes
+	assert.Equal(t, err1, fl.Reload())
+	assert.Nil(t, os.WriteFile(path, []byte(`{"x": "z"}`), 0o644))
+	assert.Nil(t, fl.Reload())
+	s, _ = fl.GetString("x")
+	assert.Equal(t, "z", s)
+	assert.Nil(t, os.WriteFile(path, []byte(`{"x": `), 0o644))
+	_, err = NewFileLayer("json", path, FileLayerOptions{Loader: LoadJson})
+	assert.NotNil(t, err)
+
+	// INI options
+	path = filepath.Join(dir, "b.conf")
+	assert.Nil(t, os.WriteFile(path, []byte("# comment\nx=1\nbad\n"), 0o644))
+	fl, err = NewFileLayer("ini", path, FileLayerOptions{Ini: IniOptions{CommentChars: "#"}})
+	assert.Nil(t, err)
+	s, _ = fl.GetString("x")
+	assert.Equal(t, "1", s)
+	_, err = NewFileLayer("ini", path, FileLayerOptions{Ini: IniOptions{CommentChars: "#", Strict: true}})
+	var perr *ParseError
+	assert.ErrorAs(t, err, &perr)
+	assert.Equal(t, path, perr.File)
+	assert.Equal(t, 3, perr.Line)
+}
+
+func TestFileLayerPolling(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.json")
+	assert.Nil(t, writeFileAtomic(path, []byte(`{"level": "info"}`)))
+
+	var mx sync.Mutex
+	var errs []error
+	fl, err := NewFileLayer("file", path, FileLayerOptions{
+		Interval: 5 * time.Millisecond,
+		OnError: func(err error) {
+			mx.Lock()
+			defer mx.Unlock()
+			errs = append(errs, err)
+		},
+	})
+	assert.Nil(t, err)
+
+	// changed file is reloaded in the background
+	assert.Nil(t, writeFileAtomic(path, []byte(`{"level": "debug"}`)))
+	assert.Eventually(t, func() bool {
+		s, _ := fl.GetString("level")
+		return s == "debug"
+	}, time.Second, 5*time.Millisecond)
+
+	// errors are reported
+	assert.Nil(t, writeFileAtomic(path, []byte(`{"level": `)))
+	assert.Eventually(t, func() bool {
+		mx.Lock()
+		defer mx.Unlock()
+		return len(errs) > 0
+	}, time.Second, 5*time.Millisecond)
+	s, _ := fl.GetString("level")
+	assert.Equal(t, "debug", s)
+
+	// the same error is reported only once
+	time.Sleep(50 * time.Millisecond)
+	mx.Lock()
+	assert.Len(t, errs, 1)
+	mx.Unlock()
+	assert.NotNil(t, fl.Reload())
+
+	// deleted file is reported only once
+	assert.Nil(t, os.Remove(path))
+	time.Sleep(50 * time.Millisecond)
+	mx.Lock()
+	assert.Len(t, errs, 2)
+	assert.ErrorIs(t, errs[1], os.ErrNotExist)
+	mx.Unlock()
+
+	// restored file is loaded again
+	assert.Nil(t, writeFileAtomic(path, []byte(`{"level": "warn"}`)))
+	assert.Eventually(t, func() bool {
+		s, _ := fl.GetString("level")
+		return s == "warn"
+	}, time.Second, 5*time.Millisecond)
+
+	// closed layer is not reloaded
+	fl.Close()
+	fl.Close()
+	assert.Nil(t, writeFileAtomic(path, []byte(`{"level": "trace"}`)))
+	time.Sleep(20 * time.Millisecond)
+	s, _ = fl.GetString("level")
+	assert.Equal(t, "warn", s)
+}
+
+func TestFileLayerReloadFromSubscriber(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.json")
+	assert.Nil(t, os.WriteFile(path, []byte(`{"a": "1"}`), 0o644))
+	fl, err := NewFileLayer("file", path, FileLayerOptions{})
+	assert.Nil(t, err)
+
+	// subscribers may reload the layer
+	var events []ChangeEvent
+	fl.Subscribe("", func(ev ChangeEvent) {
+		events = append(events, ev)
+		assert.Nil(t, fl.Reload())
+	})
+	assert.Nil(t, os.WriteFile(path, []byte(`{"a": "2"}`), 0o644))
+	assert.Nil(t, fl.Reload())
+	assert.Equal(t, []ChangeEvent{{"a", "1", "2", true, true, fl.Layer}}, events)
+}
+
+// writeFileAtomic replaces the file at path in one step, so a polling
+// FileLayer never observes it truncated or partially written.
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// Repeatable command-line flag collecting key=value pairs, e.g.
+//
+//	var sets config.KeyValueFlag
+//	flag.Var(&sets, "set", "override a config value, key=value")
+//
+// When loaded with LoadFlags, each pair is stored as a separate key
+type KeyValueFlag []string
+
+// Get the collected pairs, implements flag.Value
+func (f *KeyValueFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return strings.Join(*f, ",")
+}
+
+// Add a pair, implements flag.Value
+func (f *KeyValueFlag) Set(s string) error {
+	if _, _, err := parseKeyValueArg(s); err != nil {
+		return err
+	}
+	*f = append(*f, s)
+	return nil
+}
+
+func parseKeyValueArg(arg string) (string, string, error) {
+	key, value, ok := strings.Cut(arg, "=")
+	if !ok {
+		return "", "", fmt.Errorf("missing '=' in %q", arg)
+	}
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return "", "", fmt.Errorf("missing key in %q", arg)
+	}
+	return key, value, nil
+}
+
+// Load key=value arguments and store them in viewable (must be writable). All
+// the arguments are checked before any of them is stored
+func LoadArgs(viewable Viewable, args []string) error {
+	// check if viewable is writable
+	if !viewable.IsWritable() {
+		return fmt.Errorf("cannot load arguments: %w", ErrReadOnly)
+	}
+
+	keys := make([]string, len(args))
+	values := make([]string, len(args))
+	for i, arg := range args {
+		key, value, err := parseKeyValueArg(arg)
+		if err != nil {
+			return err
+		}
+		keys[i], values[i] = key, value
+	}
+	for i := range args {
+		viewable.SetString(keys[i], values[i])
+	}
+	return nil
+}
+
+// Load the explicitly set flags of the parsed flag set and store them in
+// viewable (must be writable). Flags left at their defaults are skipped, so
+// that they don't hide lower priority layers. keys maps flag names to config
+// keys, e.g. "port" to "http.server.port", flags missing from the map are
+// skipped. If keys is nil, flag names are used as keys. Flags of type
+// KeyValueFlag are always loaded as their key=value pairs
+func LoadFlags(viewable Viewable, fs *flag.FlagSet, keys map[string]string) error {
+	// check if viewable is writable
+	if !viewable.IsWritable() {
+		return fmt.Errorf("cannot load flags: %w", ErrReadOnly)
+	}
+
+	var errs []error
+	fs.Visit(func(f *flag.Flag) {
+		if kv, ok := f.Value.(*KeyValueFlag); ok {
+			if err := LoadArgs(viewable, *kv); err != nil {
+				errs = append(errs, fmt.Errorf("flag -%s: %w", f.Name, err))
+			}
+			return
+		}
+		key := f.Name
+		if keys != nil {
+			var ok bool
+			key, ok = keys[f.Name]
+			if !ok {
+				return
+			}
+		}
+		viewable.SetString(key, f.Value.String())
+	})
+	return errors.Join(errs...)
+}
+
+// Create a read-only layer with the given name, loaded from the explicitly set
+// flags of the parsed flag set, see LoadFlags for details. Add it with the
+// highest priority to let the command line override all other layers
+func NewFlagLayer(name string, fs *flag.FlagSet, keys map[string]string) (*Layer, error) {
+	l := NewLayer(name)
+	if err := LoadFlags(l, fs, keys); err != nil {
+		return nil, err
+	}
+	l.LockReadOnly()
+	return l, nil
+}
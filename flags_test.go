@@ -0,0 +1,114 @@
+package config
+
+import (
+	"flag"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadArgs(t *testing.T) {
+	l := NewLayer("args")
+	err := LoadArgs(l, []string{"http.server.port=9090", " name = my=server", "empty="})
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, listKeys(l, "", false), []string{"http.server.port", "name", "empty"})
+
+	s, ok := l.GetString("name")
+	assert.True(t, ok)
+	assert.Equal(t, " my=server", s)
+
+	// nothing is stored if any of the arguments is invalid
+	l = NewLayer("args")
+	err = LoadArgs(l, []string{"a=1", "b"})
+	assert.EqualError(t, err, `missing '=' in "b"`)
+	err = LoadArgs(l, []string{"a=1", " =2"})
+	assert.EqualError(t, err, `missing key in " =2"`)
+	assert.Zero(t, len(listKeys(l, "", false)))
+
+	// try to load to a read-only target
+	err = LoadArgs(NewEmptyView(), nil)
+	assert.ErrorIs(t, err, ErrReadOnly)
+}
+
+func newTestFlagSet(sets *KeyValueFlag) *flag.FlagSet {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Int("port", 8080, "server port")
+	fs.String("name", "default", "server name")
+	fs.Bool("verbose", false, "verbose output")
+	fs.Var(sets, "set", "override a config value")
+	return fs
+}
+
+func TestLoadFlags(t *testing.T) {
+	var sets KeyValueFlag
+	fs := newTestFlagSet(&sets)
+	err := fs.Parse([]string{"-port", "9090", "-verbose", "-set", "a.b=1", "-set", "c=2"})
+	assert.Nil(t, err)
+	assert.Equal(t, "a.b=1,c=2", sets.String())
+
+	// mapped flags only, default name is not stored
+	l := NewLayer("flags")
+	err = LoadFlags(l, fs, map[string]string{
+		"port": "http.server.port",
+		"name": "http.server.name",
+	})
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, listKeys(l, "", false), []string{"http.server.port", "a.b", "c"})
+
+	i, ok := NewView(l, "").GetInt("http.server.port")
+	assert.True(t, ok)
+	assert.EqualValues(t, 9090, i)
+
+	// flag names as keys
+	l = NewLayer("flags")
+	err = LoadFlags(l, fs, nil)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, listKeys(l, "", false), []string{"port", "verbose", "a.b", "c"})
+
+	b, ok := NewView(l, "").GetBool("verbose")
+	assert.True(t, ok)
+	assert.True(t, b)
+
+	// invalid pairs are rejected by the flag set
+	err = fs.Parse([]string{"-set", "novalue"})
+	assert.NotNil(t, err)
+
+	// try to load to a read-only target
+	err = LoadFlags(NewEmptyView(), fs, nil)
+	assert.ErrorIs(t, err, ErrReadOnly)
+}
+
+func TestFlagLayer(t *testing.T) {
+	var sets KeyValueFlag
+	fs := newTestFlagSet(&sets)
+	err := fs.Parse([]string{"-port", "9090"})
+	assert.Nil(t, err)
+
+	flags, err := NewFlagLayer("flags", fs, map[string]string{"port": "http.server.port", "name": "http.server.name"})
+	assert.Nil(t, err)
+	assert.False(t, flags.IsWritable())
+
+	// flags override the defaults, unset flags don't
+	defaults := NewLayer("defaults")
+	defaults.SetString("http.server.port", "8080")
+	defaults.SetString("http.server.name", "MyTestServer")
+	conf := NewConfig()
+	conf.AddLayer(defaults, 0)
+	conf.AddLayer(flags, 1000)
+
+	s, ok := conf.GetString("http.server.port")
+	assert.True(t, ok)
+	assert.Equal(t, "9090", s)
+
+	s, ok = conf.GetString("http.server.name")
+	assert.True(t, ok)
+	assert.Equal(t, "MyTestServer", s)
+
+	// invalid pair added directly
+	sets = append(sets, "invalid")
+	fs.Set("set", "x=1")
+	_, err = NewFlagLayer("flags", fs, nil)
+	assert.EqualError(t, err, `flag -set: missing '=' in "invalid"`)
+}
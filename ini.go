@@ -2,33 +2,46 @@ package config
 
 import (
 	"bufio"
+	"fmt"
 	"io"
+	"slices"
 	"strings"
 )
 
-func indexOfUnescapedEq(s string) int {
+func indexOfUnescapedSep(s string, seps string) int {
 	// start at 1 (0 length key is not valid)
-	st := 1
-	s = s[1:]
-
-	for {
-		idx := strings.IndexByte(s, '=')
-		if idx < 0 {
-			return -1
-		}
-		if idx == 0 || s[idx-1] != '\\' {
+	for i := 1; i < len(s); i++ {
+		if strings.IndexByte(seps, s[i]) >= 0 && !isEscapedAt(s, i) {
 			// found
-			return st + idx
+			return i
 		}
-		idx++
-		s = s[idx:]
-		st += idx
 	}
+	return -1
+}
+
+// Check if the character at index i of s is escaped, that is, preceded by an
+// odd number of backslashes
+func isEscapedAt(s string, i int) bool {
+	n := 0
+	for i > n && s[i-n-1] == '\\' {
+		n++
+	}
+	return n%2 == 1
 }
 
-var unescapes = strings.NewReplacer(
+// Trim whitespace around a key, except an escaped whitespace at its end
+func trimIniKey(key string) string {
+	trimmed := strings.TrimRight(key, " \t\f")
+	if n := len(trimmed); n < len(key) && isEscapedAt(key, n) {
+		trimmed = key[:n+1]
+	}
+	return strings.TrimLeft(trimmed, " \t\f")
+}
+
+var unescapePairs = []string{
 	`\:`, ":",
 	`\;`, ";",
+	`\[`, "[",
 	`\=`, "=",
 	`\r`, "\r",
 	`\n`, "\n",
@@ -37,64 +50,400 @@ var unescapes = strings.NewReplacer(
 	`\0`, "\000",
 	`\ `, " ",
 	`\\`, `\`,
+}
+
+var unescapes = strings.NewReplacer(unescapePairs...)
+
+// Kind of an INI line
+type iniLineKind int
+
+const (
+	iniBlank    iniLineKind = iota // empty line
+	iniComment                     // comment line
+	iniSection                     // section header
+	iniKeyValue                    // key-value pair
+	iniInvalid                     // line without separator, ignored
 )
 
+// Parsed INI line
+type iniLine struct {
+	kind      iniLineKind
+	text      string // raw text without the line ending
+	eol       string // line ending, empty for a last line without one
+	name      string // key prefix of a section, or full key of a key-value pair
+	value     string // unescaped value of a key-value pair
+	keyAt     int    // offset of the key in text
+	valueAt   int    // offset of the value in text
+	problem   string // malformed quoted value, reported in strict mode
+	problemAt int    // offset of the problem in text
+}
+
+// Syntax variant of INI files, see IniOptions
+type iniDialect struct {
+	comments     string // characters starting a comment
+	separators   string // characters separating keys and values
+	inline       bool   // allow comments after values and section headers
+	quoted       bool   // remove double quotes around values
+	foldSections bool   // convert section names to lower case
+	unescapes    *strings.Replacer
+}
+
+// Dialect of the files written by SaveIni
+var defaultIniDialect = &iniDialect{
+	comments:   ";",
+	separators: "=",
+	unescapes:  unescapes,
+}
+
+// Check if the character at index i of s starts an inline comment, that is, a
+// comment character at the beginning or after whitespace
+func (d *iniDialect) isInlineComment(s string, i int) bool {
+	return strings.IndexByte(d.comments, s[i]) >= 0 && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t' || s[i-1] == '\f')
+}
+
+// Remove an inline comment and the whitespace before it from s
+func (d *iniDialect) cutInlineComment(s string) string {
+	if !d.inline {
+		return s
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' {
+			// skip escaped character
+			i++
+		} else if d.isInlineComment(s, i) {
+			return strings.TrimRight(s[:i], " \t\f")
+		}
+	}
+	return s
+}
+
+// Unescape the value following the separator, with leading whitespace removed.
+// Returns the offset and the description of the problem if the value is
+// malformed, or -1
+func (d *iniDialect) parseValue(val string) (string, int, string) {
+	if !d.quoted || !strings.HasPrefix(val, `"`) {
+		return d.unescapes.Replace(d.cutInlineComment(val)), -1, ""
+	}
+
+	// find closing quote
+	end := -1
+	for i := 1; i < len(val) && end < 0; i++ {
+		if val[i] == '\\' {
+			i++
+		} else if val[i] == '"' {
+			end = i
+		}
+	}
+	if end < 0 {
+		return d.unescapes.Replace(val), len(val), "unterminated quoted value"
+	}
+
+	// only whitespace and comment may follow
+	rest := strings.TrimLeft(val[end+1:], " \t\f")
+	if rest != "" && !(d.inline && d.isInlineComment(val, len(val)-len(rest))) {
+		return d.unescapes.Replace(val), len(val) - len(rest), "unexpected text after quoted value"
+	}
+	return d.unescapes.Replace(val[1:end]), -1, ""
+}
+
+// Parse a line without its line ending. Section is the key prefix of the
+// section the line is in
+func (d *iniDialect) parseLine(text, section string) iniLine {
+	line := iniLine{text: text, problemAt: -1}
+
+	// check if line is empty or is a comment line
+	if text == "" {
+		line.kind = iniBlank
+		return line
+	}
+	if strings.IndexByte(d.comments, text[0]) >= 0 {
+		line.kind = iniComment
+		return line
+	}
+
+	// check if line contains a section definition
+	if header := d.cutInlineComment(text); strings.HasPrefix(header, "[") && strings.HasSuffix(header, "]") {
+		line.kind = iniSection
+		line.name = normalizePrefix(header[1 : len(header)-1])
+		if d.foldSections {
+			line.name = strings.ToLower(line.name)
+		}
+		return line
+	}
+
+	// find the first non-escaped separator
+	pos := indexOfUnescapedSep(text, d.separators)
+	if pos < 0 {
+		// invalid line
+		line.kind = iniInvalid
+		return line
+	}
+
+	// extract key and value
+	key := text[:pos]
+	val := text[pos+1:]
+
+	// trim key and value
+	key = trimIniKey(key)
+	trimmed := strings.TrimLeft(val, " \t\f")
+
+	// unescape key and value and prepend key with the section
+	line.kind = iniKeyValue
+	line.name = section + d.unescapes.Replace(key)
+	line.keyAt = len(text) - len(strings.TrimLeft(text, " \t\f"))
+	line.valueAt = len(text) - len(trimmed)
+	value, at, problem := d.parseValue(trimmed)
+	line.value = value
+	if at >= 0 {
+		line.problem, line.problemAt = problem, line.valueAt+at
+	}
+	return line
+}
+
+// Read a line from reader and parse it, see parseLine. Returns io.EOF after the
+// last line
+func (d *iniDialect) readLine(reader *bufio.Reader, section string) (iniLine, error) {
+	text, err := reader.ReadString('\n')
+	if err != nil && (err != io.EOF || text == "") {
+		return iniLine{}, err
+	}
+	text, lf := strings.CutSuffix(text, "\n")
+	text, cr := strings.CutSuffix(text, "\r") // also handle windows line encoding
+	line := d.parseLine(text, section)
+	if cr {
+		line.eol = "\r"
+	}
+	if lf {
+		line.eol += "\n"
+	}
+	return line, nil
+}
+
+// Options of LoadIniWithOptions
+type IniOptions struct {
+	// Name of the file, used in errors
+	FileName string
+	// Report lines without separator, malformed section headers, invalid
+	// section names, empty keys, duplicate keys and malformed quoted values as
+	// *ParseError, instead of skipping them or silently overwriting values
+	Strict bool
+	// Go on after parse errors and return all of them as ParseErrors, instead
+	// of stopping at the first one. Valid lines are loaded either way
+	CollectErrors bool
+
+	// Characters starting a comment line, ";" if empty
+	CommentChars string
+	// Allow comments after values and section headers. They start with a
+	// comment character at the beginning of the value or after whitespace, the
+	// whitespace before them is removed. Escape the comment character with a
+	// backslash to use it in a value
+	InlineComments bool
+	// Remove double quotes around values. Quoted values keep their whitespace
+	// and may contain comment characters, \" stands for a double quote in them
+	QuotedValues bool
+	// Convert section names to lower case
+	CaseInsensitiveSections bool
+	// Characters separating keys from values, like "=:". The first unescaped
+	// one in a line is used. "=" if empty
+	Separators string
+}
+
+// Get the dialect selected by the options
+func (opts *IniOptions) dialect() *iniDialect {
+	d := &iniDialect{
+		comments:     opts.CommentChars,
+		separators:   opts.Separators,
+		inline:       opts.InlineComments,
+		quoted:       opts.QuotedValues,
+		foldSections: opts.CaseInsensitiveSections,
+		unescapes:    unescapes,
+	}
+	if d.comments == "" {
+		d.comments = defaultIniDialect.comments
+	}
+	if d.separators == "" {
+		d.separators = defaultIniDialect.separators
+	}
+
+	// allow escaping the special characters not covered by the default escapes
+	pairs := slices.Clone(unescapePairs)
+	for _, c := range d.comments + d.separators {
+		if !slices.Contains(unescapePairs, `\`+string(c)) {
+			pairs = append(pairs, `\`+string(c), string(c))
+		}
+	}
+	if d.quoted {
+		pairs = append(pairs, `\"`, `"`)
+	}
+	if len(pairs) > len(unescapePairs) {
+		d.unescapes = strings.NewReplacer(pairs...)
+	}
+	return d
+}
+
+// Error in the syntax of an INI file
+type ParseError struct {
+	File   string // name of the file, empty if unknown
+	Line   int    // line number, starting at 1
+	Column int    // column number, starting at 1
+	Reason string // description of the error
+}
+
+func (e *ParseError) Error() string {
+	file := ""
+	if e.File != "" {
+		file = e.File + ": "
+	}
+	return fmt.Sprintf("ini: %sline %d, column %d: %s", file, e.Line, e.Column, e.Reason)
+}
+
+// List of errors in the syntax of an INI file, see IniOptions.CollectErrors
+type ParseErrors []*ParseError
+
+func (e ParseErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (e ParseErrors) Unwrap() []error {
+	ret := make([]error, len(e))
+	for i, err := range e {
+		ret[i] = err
+	}
+	return ret
+}
+
+// Check if a section name consists of non-empty parts separated by dots. The
+// empty name is valid, it selects the root section. Returns the offset of the
+// invalid part and the reason, or -1
+func (d *iniDialect) checkSectionName(name string) (int, string) {
+	if name == "" {
+		return -1, ""
+	}
+	if i := strings.IndexAny(name, "[]"+d.separators+d.comments); i >= 0 {
+		return i, fmt.Sprintf("invalid character %q in section name", name[i])
+	}
+	offset := 0
+	for _, part := range strings.Split(name, ".") {
+		if part == "" {
+			return offset, "empty part in section name"
+		}
+		if strings.TrimSpace(part) != part {
+			return offset, "whitespace around section name part"
+		}
+		offset += len(part) + 1
+	}
+	return -1, ""
+}
+
+// Describe the separators for errors, like "'=' or ':'"
+func (d *iniDialect) separatorNames() string {
+	names := make([]string, 0, len(d.separators))
+	for _, c := range d.separators {
+		names = append(names, "'"+string(c)+"'")
+	}
+	return strings.Join(names, " or ")
+}
+
+// Check a line in strict mode. Returns the offset of the error in the line and
+// the reason, or -1
+func (d *iniDialect) checkLine(line iniLine) (int, string) {
+	text := line.text
+	switch line.kind {
+	case iniSection:
+		header := d.cutInlineComment(text)
+		if i, reason := d.checkSectionName(header[1 : len(header)-1]); i >= 0 {
+			return i + 1, reason
+		}
+	case iniKeyValue, iniInvalid:
+		if strings.HasPrefix(text, "[") {
+			if i := strings.IndexByte(text, ']'); i >= 0 {
+				return i + 1, "unexpected text after section header"
+			}
+			return len(text), "unterminated section header"
+		}
+		trimmed := strings.TrimLeft(text, " \t\f")
+		switch {
+		case trimmed == "":
+			// whitespace only
+		case strings.IndexByte(d.separators, trimmed[0]) >= 0:
+			return len(text) - len(trimmed), "empty key"
+		case line.kind == iniInvalid:
+			return len(text), "missing " + d.separatorNames() + " after key"
+		case line.problemAt >= 0:
+			return line.problemAt, line.problem
+		}
+	}
+	return -1, ""
+}
+
 // Load INI config from reader and store the values in viewable (must be writable)
 func LoadIni(viewable Viewable, reader *bufio.Reader) error {
+	return LoadIniWithOptions(viewable, reader, IniOptions{})
+}
+
+// Load INI config from reader like LoadIni, with options. Parse errors are
+// reported only in strict mode, as *ParseError, or as ParseErrors if
+// collecting errors. Values of the valid lines before the first error are
+// loaded even if an error is returned
+func LoadIniWithOptions(viewable Viewable, reader io.Reader, opts IniOptions) error {
 	// check if viewable is writable
 	if !viewable.IsWritable() {
-		panic("cannot load ini config to read-only target")
+		return fmt.Errorf("cannot load ini config: %w", ErrReadOnly)
 	}
 
+	br, ok := reader.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(reader)
+	}
+	d := opts.dialect()
 	var section = ""
-	var line string
-	var err error = nil
-	for err != io.EOF {
+	var errs ParseErrors
+	defined := map[string]int{} // line numbers of the keys, strict mode only
+	for lineno := 1; ; lineno++ {
 		// read a line and handle error
-		line, err = reader.ReadString('\n')
-		if err != nil && err != io.EOF {
+		line, err := d.readLine(br, section)
+		if err == io.EOF {
+			break
+		} else if err != nil {
 			// IO error
 			return err
 		}
-		line, _ = strings.CutSuffix(line, "\n")
-		line, _ = strings.CutSuffix(line, "\r") // also handle windows line encoding
-
-		// check if line is empty or is a comment line
-		if line == "" || strings.HasPrefix(line, ";") {
-			continue
-		}
 
-		// check if line contains a section definition
-		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
-			newsection := line[1 : len(line)-1]
-			if !strings.HasSuffix(newsection, ".") && newsection != "" {
-				newsection += "."
+		// check syntax
+		if opts.Strict {
+			offset, reason := d.checkLine(line)
+			if offset < 0 && line.kind == iniKeyValue {
+				if first, ok := defined[line.name]; ok {
+					offset = line.keyAt
+					reason = fmt.Sprintf("duplicate key %q, first defined in line %d", line.name, first)
+				} else {
+					defined[line.name] = lineno
+				}
+			}
+			if offset >= 0 {
+				perr := &ParseError{opts.FileName, lineno, offset + 1, reason}
+				if !opts.CollectErrors {
+					return perr
+				}
+				errs = append(errs, perr)
+				continue
 			}
-			section = newsection
-			continue
 		}
 
-		// find the first non-escaped equal sign
-		pos := indexOfUnescapedEq(line)
-		if pos < 0 {
-			// invalid line
-			continue
+		switch line.kind {
+		case iniSection:
+			section = line.name
+		case iniKeyValue:
+			// save value
+			viewable.SetString(line.name, line.value)
 		}
-
-		// extract key and value
-		key := line[:pos]
-		val := line[pos+1:]
-
-		// trim key and value
-		key = strings.Trim(key, " \t\f")
-		val = strings.TrimLeft(val, " \t\f")
-
-		// unescape key and value and prepend key with the section
-		key = section + unescapes.Replace(key)
-		val = unescapes.Replace(val)
-
-		// save value
-		viewable.SetString(key, val)
+	}
+	if len(errs) > 0 {
+		return errs
 	}
 	return nil
 }
@@ -103,8 +452,9 @@ func escapeIniString(s string, equal bool) string {
 	// escape backslashes
 	s = strings.ReplaceAll(s, "\\", "\\\\")
 
-	// escape newlines
+	// escape line endings
 	s = strings.ReplaceAll(s, "\n", "\\n")
+	s = strings.ReplaceAll(s, "\r", "\\r")
 
 	// escape equal signs
 	if equal {
@@ -114,25 +464,131 @@ func escapeIniString(s string, equal bool) string {
 	return s
 }
 
-func saveIniInternal(viewable Viewable, writer io.Writer, head bool) {
+// Escape whitespace at the start of s, and also at the end if trailing is set,
+// as it would be trimmed when loading
+func escapeIniSpace(s string, trailing bool) string {
+	if n := len(s); trailing && n > 1 {
+		if e, ok := iniSpaceEscapes[s[n-1]]; ok {
+			s = s[:n-1] + e
+		}
+	}
+	if s != "" {
+		if e, ok := iniSpaceEscapes[s[0]]; ok {
+			s = e + s[1:]
+		}
+	}
+	return s
+}
+
+var iniSpaceEscapes = map[byte]string{
+	' ':  `\ `,
+	'\t': `\t`,
+	'\f': `\f`,
+}
+
+// Escape a key, so that its line is not read as a comment or a section header
+func escapeIniKey(key string) string {
+	s := escapeIniString(key, true)
+	if strings.HasPrefix(s, ";") || strings.HasPrefix(s, "[") {
+		s = `\` + s
+	}
+	return escapeIniSpace(s, true)
+}
+
+// Escape a value, keeping its leading whitespace
+func escapeIniValue(value string) string {
+	return escapeIniSpace(escapeIniString(value, false), false)
+}
+
+// Compare dotted keys part by part, see compareKeys
+func compareKeyPaths(a, b string) int {
+	ap, bp := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(ap) && i < len(bp); i++ {
+		if c := compareKeys(ap[i], bp[i]); c != 0 {
+			return c
+		}
+	}
+	return len(ap) - len(bp)
+}
+
+// Split a key to the section and the key within the section. Keys without a
+// dot, and keys that cannot be written in a section, like those with empty
+// parts in the section name, stay in the root section
+func iniSectionOf(key string) (section, name string) {
+	dot := strings.LastIndexByte(key, '.')
+	if dot < 0 || dot == len(key)-1 || slices.Contains(strings.Split(key[:dot], "."), "") ||
+		strings.ContainsAny(key[:dot], "\r\n") {
+		return "", key
+	}
+	return key[:dot], key[dot+1:]
+}
+
+func saveIniInternal(viewable Viewable, writer io.Writer, head bool) error {
+	var sb strings.Builder
 	if head {
-		writer.Write([]byte(";\n; This INI file was autogenerated\n;\n\n"))
+		sb.WriteString(";\n; This INI file was autogenerated\n;\n\n")
 	}
+
+	// group keys by sections
 	var keylist KeyList
 	viewable.ListKeys("", &keylist, false)
+	sections := map[string][]string{}
 	for _, key := range keylist.ToSlice() {
-		val, ok := viewable.GetString(key)
-		if ok {
-			// likely
-			writer.Write([]byte(escapeIniString(key, true)))
-			writer.Write([]byte("="))
-			writer.Write([]byte(escapeIniString(val, false)))
-			writer.Write([]byte("\n"))
+		section, name := iniSectionOf(key)
+		sections[section] = append(sections[section], name)
+	}
+	names := make([]string, 0, len(sections))
+	for section := range sections {
+		names = append(names, section)
+	}
+	// the root section is written first even if others sort before it, like
+	// numeric ones, as its keys have no header and would join the previous one
+	slices.SortFunc(names, func(a, b string) int {
+		if a == "" || b == "" {
+			return strings.Compare(a, b)
+		}
+		return compareKeyPaths(a, b)
+	})
+
+	// write sections in order, root section first without header
+	for i, section := range names {
+		if section != "" {
+			if i > 0 {
+				sb.WriteString("\n")
+			}
+			sb.WriteString("[" + section + "]\n")
+		}
+		keys := sections[section]
+		slices.SortFunc(keys, compareKeyPaths)
+		for _, key := range keys {
+			val, ok := viewable.GetString(joinKey(section, key))
+			if ok {
+				// likely
+				sb.WriteString(escapeIniKey(key))
+				sb.WriteString("=")
+				sb.WriteString(escapeIniValue(val))
+				sb.WriteString("\n")
+			}
 		}
 	}
+	_, err := io.WriteString(writer, sb.String())
+	return err
 }
 
-// Serialize viewable to writable in INI format
+// Options of SaveIniWithOptions
+type SaveIniOptions struct {
+	// Leave out the "autogenerated" comment at the beginning of the output
+	OmitHeader bool
+}
+
+// Serialize viewable to writable in INI format. Keys are grouped into sections
+// by their dotted prefix, sections and keys are sorted, so the output is
+// stable
 func SaveIni(viewable Viewable, writer io.Writer) {
 	saveIniInternal(viewable, writer, true)
 }
+
+// Serialize viewable to writable in INI format like SaveIni, with options
+func SaveIniWithOptions(viewable Viewable, writer io.Writer, opts SaveIniOptions) error {
+	return saveIniInternal(viewable, writer, !opts.OmitHeader)
+}
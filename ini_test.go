@@ -3,6 +3,7 @@ package config
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"io"
 	"slices"
 	"strings"
@@ -41,32 +42,72 @@ func (w *sortingWriter) GetData() string {
 }
 
 func TestSaveIniReal(t *testing.T) {
-	// otherwise, heading is skipped and results are sorted, but this test tests the original output
+	// test the exact output, including the heading
 	l := NewLayer("test")
 	l.SetString("test.key", "value")
 	l.SetString("my.dummy.stuff", "12345")
+	l.SetString("my.dummy.list.10", "c")
+	l.SetString("my.dummy.list.2", "b")
+	l.SetString("my.dummy.aaa", "a")
+	l.SetString("root", "r")
+	l.SetString("test.", "e")
 	var buf bytes.Buffer
 	SaveIni(l, &buf)
 	s := buf.String()
 
-	// exp1 and exp2 differs only in order of the two values
-	exp1 := `;
+	// keys are grouped into sections, sections and keys are sorted
+	assert.Equal(t, `;
 ; This INI file was autogenerated
 ;
 
-test.key=value
-my.dummy.stuff=12345
-`
-	exp2 := `;
-; This INI file was autogenerated
-;
+root=r
+test.=e
 
-my.dummy.stuff=12345
-test.key=value
-`
-	if s != exp1 && s != exp2 {
-		assert.Fail(t, "ini output unexpected: ", s)
+[my.dummy]
+aaa=a
+stuff=12345
+
+[my.dummy.list]
+2=b
+10=c
+
+[test]
+key=value
+`, s)
+
+	// saving again gives the same output
+	for i := 0; i < 10; i++ {
+		buf.Reset()
+		SaveIni(l, &buf)
+		assert.Equal(t, s, buf.String())
 	}
+
+	// the output loads with the same values
+	l2 := NewLayer("test2")
+	assert.Nil(t, LoadIni(l2, bufio.NewReader(bytes.NewBufferString(s))))
+	assert.Equal(t, l.values, l2.values)
+
+	// without header
+	buf.Reset()
+	err := SaveIniWithOptions(l, &buf, SaveIniOptions{OmitHeader: true})
+	assert.Nil(t, err)
+	assert.Equal(t, s[len(";\n; This INI file was autogenerated\n;\n\n"):], buf.String())
+
+	// only sections
+	buf.Reset()
+	err = SaveIniWithOptions(NewView(l, "my"), &buf, SaveIniOptions{OmitHeader: true})
+	assert.Nil(t, err)
+	assert.Equal(t, "[dummy]\naaa=a\nstuff=12345\n\n[dummy.list]\n2=b\n10=c\n", buf.String())
+
+	// write error
+	var fw fakeIniTestErrorWriter
+	assert.Equal(t, io.ErrShortWrite, SaveIniWithOptions(l, &fw, SaveIniOptions{}))
+}
+
+type fakeIniTestErrorWriter struct{}
+
+func (w *fakeIniTestErrorWriter) Write(buf []byte) (int, error) {
+	return 0, io.ErrShortWrite
 }
 
 func TestSaveIni(t *testing.T) {
@@ -87,6 +128,58 @@ key\\2=value2
 xx=yy\nzz`, w.GetData())
 }
 
+func TestSaveIniRoundTrip(t *testing.T) {
+	// keys and values that need escaping to load back the same
+	l := NewLayer("test")
+	l.SetString("a.;b", "1")
+	l.SetString("a.[c", "2]")
+	l.SetString("a..b", "3")
+	l.SetString("a. b", "4")
+	l.SetString(".a", "6")
+	l.SetString(";root", "7")
+	l.SetString("a.x", " padded")
+	l.SetString("a.y", "cr\r")
+	l.SetString("r ", "8")
+	l.SetString("a.b ", "5")
+	l.SetString("a.t\t", "9")
+	l.SetString(`k\`, "10")
+	l.SetString(`a.k\ `, "11")
+	var buf bytes.Buffer
+	assert.Nil(t, SaveIniWithOptions(l, &buf, SaveIniOptions{OmitHeader: true}))
+	assert.Equal(t, `.a=6
+\;root=7
+a..b=3
+k\\=10
+r\ =8
+
+[a]
+\ b=4
+\;b=1
+\[c=2]
+b\ =5
+k\\\ =11
+t\t=9
+x=\ padded
+y=cr\r
+`, buf.String())
+
+	l2 := NewLayer("test2")
+	assert.Nil(t, LoadIni(l2, bufio.NewReader(&buf)))
+	assert.Equal(t, l.values, l2.values)
+
+	// root keys are written before numeric sections
+	l = NewLayer("test")
+	l.SetString("0.x", "1")
+	l.SetString("y", "2")
+	buf.Reset()
+	assert.Nil(t, SaveIniWithOptions(l, &buf, SaveIniOptions{OmitHeader: true}))
+	assert.Equal(t, "y=2\n\n[0]\nx=1\n", buf.String())
+
+	l2 = NewLayer("test2")
+	assert.Nil(t, LoadIni(l2, bufio.NewReader(&buf)))
+	assert.Equal(t, l.values, l2.values)
+}
+
 func TestLoadIni(t *testing.T) {
 	buf := bytes.NewBufferString(`
 my.key.1 = value1
@@ -109,9 +202,8 @@ my.key.2 = value2`)
 	assert.Equal(t, "value2", s)
 
 	// try to load ini to a read-only target
-	assert.Panics(t, func() {
-		LoadIni(NewEmptyView(), bufio.NewReader(buf))
-	})
+	err = LoadIni(NewEmptyView(), bufio.NewReader(buf))
+	assert.ErrorIs(t, err, ErrReadOnly)
 }
 
 func TestLoadIniWithEscapes(t *testing.T) {
@@ -220,3 +312,138 @@ func TestLoadIniIoError(t *testing.T) {
 	assert.True(t, ok)
 	assert.Equal(t, "value", s)
 }
+
+func TestLoadIniStrict(t *testing.T) {
+	src := `; comment
+a=1
+
+[http]
+port=80
+   
+[http.tls]
+cert=a.pem
+`
+	// valid file
+	l := NewLayer("test")
+	err := LoadIniWithOptions(l, bytes.NewBufferString(src), IniOptions{FileName: "app.ini", Strict: true})
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, listKeys(l, "", false), []string{"a", "http.port", "http.tls.cert"})
+
+	// errors with line and column
+	invalid := map[string]*ParseError{
+		"a=1\nnoequal\n":            {"", 2, 8, "missing '=' after key"},
+		"[section\n":                {"", 1, 9, "unterminated section header"},
+		"[section]x=1\n":            {"", 1, 10, "unexpected text after section header"},
+		"=value\n":                  {"", 1, 1, "empty key"},
+		"  = value\n":               {"", 1, 3, "empty key"},
+		"[a..b]\n":                  {"", 1, 4, "empty part in section name"},
+		"[.a]\n":                    {"", 1, 2, "empty part in section name"},
+		"[a. b]\n":                  {"", 1, 4, "whitespace around section name part"},
+		"[a=b]\n":                   {"", 1, 3, "invalid character '=' in section name"},
+		"a=1\n[x]\nb=2\n[]\n a = 3": {"", 5, 2, `duplicate key "a", first defined in line 1`},
+		"[s]\na=1\n[s]\na=2":        {"", 4, 1, `duplicate key "s.a", first defined in line 2`},
+	}
+	for in, perr := range invalid {
+		err := LoadIniWithOptions(NewLayer("test"), bytes.NewBufferString(in), IniOptions{Strict: true})
+		assert.Equal(t, perr, err, in)
+	}
+
+	// not strict
+	for in := range invalid {
+		err := LoadIniWithOptions(NewLayer("test"), bytes.NewBufferString(in), IniOptions{})
+		assert.Nil(t, err, in)
+	}
+
+	// file name in error, values before the error are loaded
+	l = NewLayer("test")
+	err = LoadIniWithOptions(l, bytes.NewBufferString("a=1\nb\nc=3\n"), IniOptions{FileName: "app.ini", Strict: true})
+	assert.Equal(t, "ini: app.ini: line 2, column 2: missing '=' after key", err.Error())
+	assert.Equal(t, map[string]string{"a": "1"}, l.values)
+
+	// collect all errors
+	l = NewLayer("test")
+	err = LoadIniWithOptions(l, bytes.NewBufferString("a=1\nb\nc=3\n[x\na=2\n"), IniOptions{Strict: true, CollectErrors: true})
+	var perrs ParseErrors
+	assert.True(t, errors.As(err, &perrs))
+	assert.Equal(t, ParseErrors{
+		{"", 2, 2, "missing '=' after key"},
+		{"", 4, 3, "unterminated section header"},
+		{"", 5, 1, `duplicate key "a", first defined in line 1`},
+	}, perrs)
+	assert.Equal(t, "ini: line 2, column 2: missing '=' after key\n"+
+		"ini: line 4, column 3: unterminated section header\n"+
+		`ini: line 5, column 1: duplicate key "a", first defined in line 1`, err.Error())
+	var perr *ParseError
+	assert.True(t, errors.As(err, &perr))
+	assert.Equal(t, 2, perr.Line)
+	assert.Equal(t, map[string]string{"a": "1", "c": "3"}, l.values)
+
+	// read-only target
+	err = LoadIniWithOptions(NewEmptyView(), bytes.NewBufferString(src), IniOptions{})
+	assert.ErrorIs(t, err, ErrReadOnly)
+}
+
+func TestLoadIniDialect(t *testing.T) {
+	src := `# exported by another tool
+; semicolons are comments too
+[Server] # main section
+Name: "my server"   ; quoted value
+path = /srv/app # trailing comment
+color = red\#1
+quote = "say \"hi\" # not a comment"
+empty = ""
+spaces = "  padded  "
+url: http://host:8080/a#b
+[server.TLS]
+enabled=true
+`
+	l := NewLayer("test")
+	err := LoadIniWithOptions(l, bytes.NewBufferString(src), IniOptions{
+		Strict:                  true,
+		CommentChars:            "#;",
+		InlineComments:          true,
+		QuotedValues:            true,
+		CaseInsensitiveSections: true,
+		Separators:              "=:",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{
+		"server.Name":        "my server",
+		"server.path":        "/srv/app",
+		"server.color":       "red#1",
+		"server.quote":       `say "hi" # not a comment`,
+		"server.empty":       "",
+		"server.spaces":      "  padded  ",
+		"server.url":         "http://host:8080/a#b",
+		"server.tls.enabled": "true",
+	}, l.values)
+
+	// default dialect keeps all of these literally
+	l = NewLayer("test")
+	err = LoadIniWithOptions(l, bytes.NewBufferString("#a=1\n[S] ; x\nk = \"v\" ; c\n"), IniOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"#a": "1", "k": `"v" ; c`}, l.values)
+
+	// quote problems
+	opts := IniOptions{Strict: true, QuotedValues: true, Separators: ":"}
+	invalid := map[string]*ParseError{
+		`k: "abc`:      {"", 1, 8, "unterminated quoted value"},
+		`k: "abc" def`: {"", 1, 10, "unexpected text after quoted value"},
+		`k: "a" ; c`:   {"", 1, 8, "unexpected text after quoted value"},
+		`k= v`:         {"", 1, 5, "missing ':' after key"},
+		`[a:b]`:        {"", 1, 3, "invalid character ':' in section name"},
+		`: v`:          {"", 1, 1, "empty key"},
+	}
+	for in, perr := range invalid {
+		err := LoadIniWithOptions(NewLayer("test"), bytes.NewBufferString(in), opts)
+		assert.Equal(t, perr, err, in)
+	}
+	err = LoadIniWithOptions(NewLayer("test"), bytes.NewBufferString("a"), IniOptions{Strict: true, Separators: "=:"})
+	assert.Equal(t, "ini: line 1, column 2: missing '=' or ':' after key", err.Error())
+
+	// not strict, malformed quoted values are kept literally
+	l = NewLayer("test")
+	err = LoadIniWithOptions(l, bytes.NewBufferString("a=\"x\nb=\"x\" y\n"), IniOptions{QuotedValues: true})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"a": `"x`, "b": `"x" y`}, l.values)
+}
@@ -0,0 +1,211 @@
+package config
+
+import (
+	"bufio"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Editable INI document keeping comments, blank lines and the order of
+// sections and keys. It implements Viewable, so it can be edited through views
+// or filled with Marshal. Changed values are replaced in place, deleted keys
+// remove their lines, and new keys are appended to the matching section, so
+// writing the document back changes only the edited lines
+type IniDocument struct {
+	mx     sync.Mutex
+	lines  []iniLine
+	values map[string]string
+	eol    string // line ending of new lines
+}
+
+// Create an empty INI document
+func NewIniDocument() *IniDocument {
+	return &IniDocument{
+		values: map[string]string{},
+		eol:    "\n",
+	}
+}
+
+// Parse an INI document from reader, see LoadIni for the syntax
+func ParseIniDocument(reader io.Reader) (*IniDocument, error) {
+	doc := NewIniDocument()
+	br := bufio.NewReader(reader)
+	section := ""
+	for {
+		line, err := defaultIniDialect.readLine(br, section)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		// use the line ending of the first line for new lines
+		if len(doc.lines) == 0 && line.eol != "" {
+			doc.eol = line.eol
+		}
+		switch line.kind {
+		case iniSection:
+			section = line.name
+		case iniKeyValue:
+			doc.values[line.name] = line.value
+		}
+		doc.lines = append(doc.lines, line)
+	}
+	return doc, nil
+}
+
+// Write the document to writer, implements io.WriterTo
+func (d *IniDocument) WriteTo(writer io.Writer) (int64, error) {
+	// lock mutex
+	d.mx.Lock()
+	defer d.mx.Unlock()
+
+	var sb strings.Builder
+	for _, line := range d.lines {
+		sb.WriteString(line.text)
+		sb.WriteString(line.eol)
+	}
+	n, err := io.WriteString(writer, sb.String())
+	return int64(n), err
+}
+
+// Test if the document is writable, always true
+func (d *IniDocument) IsWritable() bool {
+	return true
+}
+
+// Get raw string value for the given key
+func (d *IniDocument) GetString(key string) (string, bool) {
+	// lock mutex
+	d.mx.Lock()
+	defer d.mx.Unlock()
+
+	value, found := d.values[key]
+	return value, found
+}
+
+// Set raw string value for the given key. The last line defining the key is
+// updated, keeping the key and the spacing around the equal sign. A new key is
+// added after the last key of the longest matching section, a new section is
+// appended for it if there is no matching section
+func (d *IniDocument) SetString(key, value string) {
+	// lock mutex
+	d.mx.Lock()
+	defer d.mx.Unlock()
+
+	d.values[key] = value
+
+	// update the effective line in place
+	for i := len(d.lines) - 1; i >= 0; i-- {
+		line := &d.lines[i]
+		if line.kind == iniKeyValue && line.name == key {
+			line.text = line.text[:line.valueAt] + escapeIniValue(value)
+			line.value = value
+			return
+		}
+	}
+	d.insertKey(key, value)
+}
+
+// Lines of a section block, from the line after the header until the next
+// header. Lines before the first header form a root block
+type iniBlock struct {
+	name       string
+	start, end int
+}
+
+// Add a new key-value line, see SetString
+func (d *IniDocument) insertKey(key, value string) {
+	// split lines into blocks
+	blocks := []iniBlock{{"", 0, len(d.lines)}}
+	for i, line := range d.lines {
+		if line.kind == iniSection {
+			blocks[len(blocks)-1].end = i
+			blocks = append(blocks, iniBlock{line.name, i + 1, len(d.lines)})
+		}
+	}
+
+	// find the last block of the longest section matching the key
+	block := blocks[0]
+	for _, b := range blocks {
+		if strings.HasPrefix(key, b.name) && len(b.name) >= len(block.name) {
+			block = b
+		}
+	}
+
+	// create a new section for dotted keys not matching any section
+	if block.name == "" {
+		if section, _ := iniSectionOf(key); section != "" {
+			d.appendSection(section)
+			block = iniBlock{section + ".", len(d.lines), len(d.lines)}
+		}
+	}
+
+	// insert after the last non-blank line of the block, keep the missing line
+	// ending at the end of the document
+	pos := block.end
+	for pos > block.start && d.lines[pos-1].kind == iniBlank {
+		pos--
+	}
+	line := defaultIniDialect.parseLine(escapeIniKey(key[len(block.name):])+"="+escapeIniValue(value), block.name)
+	line.eol = d.eol
+	if pos == len(d.lines) && pos > 0 && d.lines[pos-1].eol == "" {
+		d.lines[pos-1].eol = d.eol
+		line.eol = ""
+	}
+	d.lines = slices.Insert(d.lines, pos, line)
+}
+
+// Append a new section header, separated by a blank line
+func (d *IniDocument) appendSection(name string) {
+	d.ensureEol(len(d.lines) - 1)
+	if n := len(d.lines); n > 0 && d.lines[n-1].kind != iniBlank {
+		d.lines = append(d.lines, iniLine{kind: iniBlank, eol: d.eol})
+	}
+	d.lines = append(d.lines, defaultIniDialect.parseLine("["+name+"]", ""))
+	d.lines[len(d.lines)-1].eol = d.eol
+}
+
+// Make sure the line at the index has a line ending, so that a line can follow
+// it. Index may be -1
+func (d *IniDocument) ensureEol(i int) {
+	if i >= 0 && d.lines[i].eol == "" {
+		d.lines[i].eol = d.eol
+	}
+}
+
+// Delete value for the given key. All lines defining the key are removed
+func (d *IniDocument) DeleteValue(key string) {
+	// lock mutex
+	d.mx.Lock()
+	defer d.mx.Unlock()
+
+	if _, ok := d.values[key]; !ok {
+		return
+	}
+	delete(d.values, key)
+	lines := d.lines[:0]
+	for _, line := range d.lines {
+		if line.kind != iniKeyValue || line.name != key {
+			lines = append(lines, line)
+		}
+	}
+	d.lines = lines
+}
+
+// List keys, see Viewable for details
+func (d *IniDocument) ListKeys(prefix string, out *KeyList, direct bool) {
+	prefix = normalizePrefix(prefix)
+
+	// lock mutex
+	d.mx.Lock()
+	defer d.mx.Unlock()
+
+	for k := range d.values {
+		if strings.HasPrefix(k, prefix) {
+			out.addKey(directKey(k[len(prefix):], direct))
+		}
+	}
+}
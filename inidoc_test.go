@@ -0,0 +1,136 @@
+package config
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeIniDocument(t *testing.T, doc *IniDocument) string {
+	var buf bytes.Buffer
+	n, err := doc.WriteTo(&buf)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(buf.Len()), n)
+	return buf.String()
+}
+
+func TestIniDocumentRoundTrip(t *testing.T) {
+	src := `; global settings
+name = my app
+
+[http]
+; listening port
+port = 8080
+host=localhost
+invalid line
+
+[log]
+level=info
+`
+	doc, err := ParseIniDocument(strings.NewReader(src))
+	assert.Nil(t, err)
+	assert.Equal(t, src, writeIniDocument(t, doc))
+	assert.True(t, doc.IsWritable())
+	assert.ElementsMatch(t, listKeys(doc, "", false), []string{"name", "http.port", "http.host", "log.level"})
+	assert.ElementsMatch(t, listKeys(doc, "http", true), []string{"port", "host"})
+	s, ok := doc.GetString("name")
+	assert.True(t, ok)
+	assert.Equal(t, "my app", s)
+
+	// edit in place
+	doc.SetString("http.port", "9090")
+	doc.SetString("name", "x=y")
+	doc.DeleteValue("http.host")
+	doc.DeleteValue("noexist")
+	assert.Equal(t, `; global settings
+name = x=y
+
+[http]
+; listening port
+port = 9090
+invalid line
+
+[log]
+level=info
+`, writeIniDocument(t, doc))
+
+	// keys and values needing escapes
+	doc.SetString("log.;b", "2")
+	doc.SetString("log.level", " padded")
+	doc.SetString("x..y", "3")
+	doc.SetString("x.[z", "4]")
+	assert.Equal(t, `; global settings
+name = x=y
+x..y=3
+
+[http]
+; listening port
+port = 9090
+invalid line
+
+[log]
+level=\ padded
+\;b=2
+
+[x]
+\[z=4]
+`, writeIniDocument(t, doc))
+
+	// the written document loads with the same values
+	l := NewLayer("test")
+	assert.Nil(t, LoadIniWithOptions(l, strings.NewReader(writeIniDocument(t, doc)), IniOptions{}))
+	assert.Equal(t, doc.values, l.values)
+}
+
+func TestIniDocumentNewKeys(t *testing.T) {
+	doc, err := ParseIniDocument(strings.NewReader("top=1\r\n\r\n[a]\r\nx=1\r\n\r\n[a.b]\r\ny=2\r\n\r\n[c]\r\nz=3\r\n\r\n[a]\r\nw=4"))
+	assert.Nil(t, err)
+
+	// added to the last block of the longest matching section
+	doc.SetString("a.new", "5")
+	doc.SetString("a.b.new", "6")
+	doc.SetString("c.d.new", "7")
+	doc.SetString("root", "8")
+
+	// new section
+	doc.SetString("e.f.g", "9")
+	doc.SetString("e.f.h", "10")
+	assert.Equal(t, "top=1\r\nroot=8\r\n\r\n[a]\r\nx=1\r\n\r\n[a.b]\r\ny=2\r\nnew=6\r\n\r\n[c]\r\nz=3\r\nd.new=7\r\n\r\n[a]\r\nw=4\r\nnew=5\r\n\r\n[e.f]\r\ng=9\r\nh=10\r\n", writeIniDocument(t, doc))
+
+	// missing final line ending is kept
+	doc, err = ParseIniDocument(strings.NewReader("[a]\nx=1"))
+	assert.Nil(t, err)
+	doc.SetString("a.y", "2")
+	assert.Equal(t, "[a]\nx=1\ny=2", writeIniDocument(t, doc))
+
+	// duplicate keys: the last one is effective and updated, delete removes all
+	doc, err = ParseIniDocument(strings.NewReader("k=1\nk=2\n"))
+	assert.Nil(t, err)
+	doc.SetString("k", "3")
+	assert.Equal(t, "k=1\nk=3\n", writeIniDocument(t, doc))
+	doc.DeleteValue("k")
+	assert.Equal(t, "", writeIniDocument(t, doc))
+
+	// empty document, escapes
+	doc = NewIniDocument()
+	doc.SetString("k=1", "a\nb")
+	doc.SetString("s.k", "v")
+	assert.Equal(t, "k\\=1=a\\nb\n\n[s]\nk=v\n", writeIniDocument(t, doc))
+
+	// edit through a view
+	view := NewView(doc, "s")
+	view.SetInt("n", 5)
+	n, ok := view.GetInt("n")
+	assert.True(t, ok)
+	assert.Equal(t, int64(5), n)
+	assert.Nil(t, view.TrySetString("n", "6"))
+}
+
+func TestIniDocumentIoError(t *testing.T) {
+	var rd fakeIniTestErrorReader
+	rd.data = []byte("key=value\n")
+	_, err := ParseIniDocument(&rd)
+	assert.NotNil(t, err)
+}
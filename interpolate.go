@@ -0,0 +1,203 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Error wrapped by the *InterpolationError of values referencing themselves
+// directly or through other keys
+var ErrInterpolationCycle = errors.New("reference cycle")
+
+// Error reported when the references of a value cannot be resolved
+type InterpolationError struct {
+	Key   string // full key of the value
+	Value string // raw string value
+	Err   error  // reason of the failure
+}
+
+func (e *InterpolationError) Error() string {
+	return fmt.Sprintf("config: cannot interpolate %q at key %q: %v", e.Value, e.Key, e.Err)
+}
+
+func (e *InterpolationError) Unwrap() error {
+	return e.Err
+}
+
+// State of expanding the references of a value
+type interpolation struct {
+	lookup func(key string) (string, bool) // get raw value
+	stack  []string                        // keys being expanded
+}
+
+// Expand the references in the raw value of the given key. The following
+// forms are supported:
+//   - ${other.key}: value of another key, also expanded
+//   - ${env:NAME}: value of an environment variable
+//   - ${other.key:-default}, ${env:NAME:-default}: default is used if the
+//     referenced value is missing or empty, it may also contain references
+//   - $${: literal "${"
+func interpolate(key, value string, lookup func(key string) (string, bool)) (string, error) {
+	if !strings.Contains(value, "${") {
+		return value, nil
+	}
+	in := interpolation{lookup, []string{key}}
+	ret, err := in.expand(value)
+	if err != nil {
+		return "", &InterpolationError{key, value, err}
+	}
+	return ret, nil
+}
+
+// Find the index of the brace closing the reference starting at s[start],
+// handling nested references. Returns -1 if not closed
+func closingBrace(s string, start int) int {
+	depth := 0
+	for i := start; i < len(s); i++ {
+		if strings.HasPrefix(s[i:], "${") {
+			depth++
+			i++
+		} else if s[i] == '}' {
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func (in *interpolation) expand(s string) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < len(s); {
+		switch {
+		case strings.HasPrefix(s[i:], "$${"):
+			// escaped
+			sb.WriteString("${")
+			i += 3
+		case strings.HasPrefix(s[i:], "${"):
+			// reference
+			end := closingBrace(s, i)
+			if end < 0 {
+				return "", fmt.Errorf("unterminated reference at offset %d", i)
+			}
+			v, err := in.resolve(s[i+2 : end])
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(v)
+			i = end + 1
+		default:
+			sb.WriteByte(s[i])
+			i++
+		}
+	}
+	return sb.String(), nil
+}
+
+// Get the value of the reference between the braces
+func (in *interpolation) resolve(ref string) (string, error) {
+	name, def, hasDef := strings.Cut(ref, ":-")
+	if name == "" {
+		return "", fmt.Errorf("empty reference %q", "${"+ref+"}")
+	}
+
+	var value string
+	var found bool
+	if env, ok := strings.CutPrefix(name, "env:"); ok {
+		value, found = os.LookupEnv(env)
+	} else {
+		// check for cycles
+		if slices.Contains(in.stack, name) {
+			path := append(slices.Clone(in.stack[slices.Index(in.stack, name):]), name)
+			return "", fmt.Errorf("%w: %s", ErrInterpolationCycle, strings.Join(path, " -> "))
+		}
+
+		// expand the referenced value
+		var raw string
+		raw, found = in.lookup(name)
+		if found {
+			in.stack = append(in.stack, name)
+			var err error
+			value, err = in.expand(raw)
+			in.stack = in.stack[:len(in.stack)-1]
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+
+	// use default if missing or empty
+	if hasDef && value == "" {
+		return in.expand(def)
+	}
+	if !found {
+		return "", fmt.Errorf("undefined reference %q", name)
+	}
+	return value, nil
+}
+
+// Enable or disable expanding references in the values, see interpolate for
+// the syntax. References are resolved through the whole config, so a higher
+// priority layer may override the referenced value. Disabled by default
+func (c *Config) SetInterpolation(enabled bool) {
+	c.interpolation.Store(enabled)
+}
+
+// Get the value for the given key with its references expanded if
+// interpolation is enabled. The error is a *InterpolationError if the
+// references cannot be resolved
+func (c *Config) getExpanded(key string) (string, bool, error) {
+	raw, found := c.GetRawString(key)
+	if !found || !c.interpolation.Load() {
+		return raw, found, nil
+	}
+	ret, err := interpolate(key, raw, c.GetRawString)
+	return ret, true, err
+}
+
+// Get raw string value for the given key without expanding its references
+func (c *Config) GetRawString(key string) (string, bool) {
+	// try the cache first
+	cache := c.cache.Load()
+	if cache != nil {
+		if st, ok := cache.Load(key); ok {
+			return st.(keyState).value, st.(keyState).found
+		}
+	}
+
+	// find in all layers
+	st := c.effectiveValue(key, nil, keyState{})
+	if cache != nil {
+		cache.Store(key, st)
+	}
+	return st.value, st.found
+}
+
+// Optional interface of viewables expanding references in values, like Config
+type expander interface {
+	GetRawString(key string) (string, bool)
+	getExpanded(key string) (string, bool, error)
+}
+
+// Get raw string value for the given key without expanding its references,
+// see Config.SetInterpolation. Same as GetString if the viewable does not
+// support interpolation
+func (view View) GetRawString(key string) (value string, found bool) {
+	if e, ok := view.viewable.(expander); ok {
+		return e.GetRawString(view.deriveKey(key))
+	}
+	return view.GetString(key)
+}
+
+// Get string value for the given key, reporting interpolation errors
+func (view View) getExpanded(key string) (string, bool, error) {
+	if e, ok := view.viewable.(expander); ok {
+		return e.getExpanded(view.deriveKey(key))
+	}
+	s, ok := view.GetString(key)
+	return s, ok, nil
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterpolate(t *testing.T) {
+	t.Setenv("CONFIG_TEST_HOME", "/home/test")
+	t.Setenv("CONFIG_TEST_EMPTY", "")
+	values := staticViewable{
+		"base":  "/srv/app",
+		"data":  "${base}/data",
+		"cache": "${data}/cache",
+		"empty": "",
+		"a":     "${b}",
+		"b":     "x${c}",
+		"c":     "${a}",
+		"self":  "${self}",
+	}
+	valid := map[string]string{
+		"plain":                           "plain",
+		"${base}":                         "/srv/app",
+		"${cache}/x":                      "/srv/app/data/cache/x",
+		"${env:CONFIG_TEST_HOME}/.app":    "/home/test/.app",
+		"${missing:-def}":                 "def",
+		"${empty:-def}":                   "def",
+		"${env:CONFIG_TEST_EMPTY:-def}":   "def",
+		"${env:CONFIG_TEST_MISSING:-def}": "def",
+		"${missing:-${base}/x}":           "/srv/app/x",
+		"${base:-def}":                    "/srv/app",
+		"${missing:-}":                    "",
+		"$${base}":                        "${base}",
+		"$$${base}":                       "$${base}",
+		"$ {base} $base {base}":           "$ {base} $base {base}",
+		"${base}${base}":                  "/srv/app/srv/app",
+	}
+	for in, out := range valid {
+		ret, err := interpolate("key", in, values.GetString)
+		assert.Nil(t, err, in)
+		assert.Equal(t, out, ret, in)
+	}
+
+	invalid := map[string]string{
+		"${missing}":                 `undefined reference "missing"`,
+		"${env:CONFIG_TEST_MISSING}": `undefined reference "env:CONFIG_TEST_MISSING"`,
+		"${base":                     "unterminated reference at offset 0",
+		"x${}":                       `empty reference "${}"`,
+		"${a}":                       "reference cycle: a -> b -> c -> a",
+		"${key}":                     "reference cycle: key -> key",
+	}
+	for in, msg := range invalid {
+		_, err := interpolate("key", in, values.GetString)
+		var ierr *InterpolationError
+		if assert.True(t, errors.As(err, &ierr), in) {
+			assert.Equal(t, "key", ierr.Key)
+			assert.Equal(t, in, ierr.Value)
+			assert.Equal(t, msg, ierr.Err.Error(), in)
+		}
+	}
+	_, err := interpolate("self", "${self}", values.GetString)
+	assert.ErrorIs(t, err, ErrInterpolationCycle)
+	assert.Equal(t, `config: cannot interpolate "${self}" at key "self": reference cycle: self -> self`, err.Error())
+}
+
+func TestConfigInterpolation(t *testing.T) {
+	defaults := NewLayer("defaults")
+	defaults.SetString("paths.base", "/srv/app")
+	defaults.SetString("paths.data", "${paths.base}/data")
+	defaults.SetString("loop", "${loop}")
+	overrides := NewLayer("overrides")
+
+	conf := NewConfig()
+	conf.AddLayer(defaults, 0)
+	conf.AddWritableLayer(overrides, 10)
+
+	// disabled by default
+	s, _ := conf.GetString("paths.data")
+	assert.Equal(t, "${paths.base}/data", s)
+
+	// references are resolved through the config
+	conf.SetInterpolation(true)
+	s, _ = conf.GetString("paths.data")
+	assert.Equal(t, "/srv/app/data", s)
+	conf.SetString("paths.base", "/opt/app")
+	view := NewView(conf, "paths")
+	s, _ = view.GetString("data")
+	assert.Equal(t, "/opt/app/data", s)
+
+	// raw value
+	s, ok := view.GetRawString("data")
+	assert.True(t, ok)
+	assert.Equal(t, "${paths.base}/data", s)
+	s, ok = conf.GetRawString("paths.data")
+	assert.True(t, ok)
+	assert.Equal(t, "${paths.base}/data", s)
+	s, _ = NewView(defaults, "paths").GetRawString("data")
+	assert.Equal(t, "${paths.base}/data", s)
+
+	// errors are reported by lookup, get reports not found
+	_, ok = conf.GetString("loop")
+	assert.False(t, ok)
+	_, err := NewView(conf, "").LookupString("loop")
+	assert.ErrorIs(t, err, ErrInterpolationCycle)
+	_, err = NewView(conf, "").LookupInt("loop")
+	assert.ErrorIs(t, err, ErrInterpolationCycle)
+	s, err = view.LookupString("data")
+	assert.Nil(t, err)
+	assert.Equal(t, "/opt/app/data", s)
+
+	// unmarshal
+	var out struct {
+		Loop string
+	}
+	err = Unmarshal(NewView(conf, ""), &out)
+	assert.ErrorIs(t, err, ErrInterpolationCycle)
+
+	// snapshots resolve references within the snapshot
+	snap := conf.Snapshot()
+	conf.SetString("paths.base", "/usr/app")
+	s, _ = snap.GetString("paths.data")
+	assert.Equal(t, "/opt/app/data", s)
+	s, _ = NewView(snap, "").GetRawString("paths.data")
+	assert.Equal(t, "${paths.base}/data", s)
+	_, err = NewView(snap, "").LookupString("loop")
+	assert.ErrorIs(t, err, ErrInterpolationCycle)
+
+	// works with caching
+	conf.SetCaching(true)
+	s, _ = conf.GetString("paths.data")
+	assert.Equal(t, "/usr/app/data", s)
+	conf.SetString("paths.base", "/")
+	s, _ = conf.GetString("paths.data")
+	assert.Equal(t, "//data", s)
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+func loadJsonValue(viewable Viewable, key string, value any) {
+	switch v := value.(type) {
+	case map[string]any:
+		for k, child := range v {
+			loadJsonValue(viewable, joinKey(key, k), child)
+		}
+	case []any:
+		for i, child := range v {
+			loadJsonValue(viewable, joinKey(key, strconv.Itoa(i)), child)
+		}
+	case string:
+		viewable.SetString(key, v)
+	case json.Number:
+		viewable.SetString(key, v.String())
+	case bool:
+		viewable.SetString(key, strconv.FormatBool(v))
+	case nil:
+		// null values are skipped
+	}
+}
+
+// Load JSON config from reader and store the values in viewable (must be
+// writable). Nested objects are mapped to dotted keys, arrays to indexed keys
+// and scalars to their raw string form
+func LoadJson(viewable Viewable, reader io.Reader) error {
+	// check if viewable is writable
+	if !viewable.IsWritable() {
+		return fmt.Errorf("cannot load json config: %w", ErrReadOnly)
+	}
+
+	// decode the whole document, keep numbers in their original form
+	dec := json.NewDecoder(reader)
+	dec.UseNumber()
+	var root any
+	if err := dec.Decode(&root); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("json: unexpected data after top-level value")
+	}
+
+	// root must be an object
+	obj, ok := root.(map[string]any)
+	if !ok {
+		return errors.New("json: top-level value must be an object")
+	}
+	loadJsonValue(viewable, "", obj)
+	return nil
+}
+
+func jsonValueOf(node *keyTree, path string) (any, error) {
+	if err := node.check(path); err != nil {
+		return nil, err
+	}
+	if node.hasValue {
+		return node.value, nil
+	}
+
+	// indexed keys become arrays
+	if node.isList() {
+		ret := make([]any, len(node.children))
+		for i := range ret {
+			k := strconv.Itoa(i)
+			v, err := jsonValueOf(node.children[k], joinKey(path, k))
+			if err != nil {
+				return nil, err
+			}
+			ret[i] = v
+		}
+		return ret, nil
+	}
+
+	// anything else is an object
+	return jsonObjectOf(node, path)
+}
+
+func jsonObjectOf(node *keyTree, path string) (map[string]any, error) {
+	ret := make(map[string]any, len(node.children))
+	for k, child := range node.children {
+		v, err := jsonValueOf(child, joinKey(path, k))
+		if err != nil {
+			return nil, err
+		}
+		ret[k] = v
+	}
+	return ret, nil
+}
+
+// Serialize viewable to writer in JSON format. Dotted keys are turned into
+// nested objects and indexed keys into arrays, all values are written as
+// strings
+func SaveJson(viewable Viewable, writer io.Writer) error {
+	// root is always an object, even if its keys look like indices
+	root, err := jsonObjectOf(buildKeyTree(viewable), "")
+	if err != nil {
+		return fmt.Errorf("json: %w", err)
+	}
+
+	enc := json.NewEncoder(writer)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(root)
+}
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadJson(t *testing.T) {
+	buf := bytes.NewBufferString(`{
+	"http": {
+		"server": {
+			"port": 8080,
+			"multiconnections": false,
+			"servername": "MyTestServer v0.9"
+		},
+		"hosts": ["a", "b", {"name": "c"}]
+	},
+	"ratio": 1.5e3,
+	"nothing": null,
+	"dotted.key": "x"
+}`)
+
+	l := NewLayer("test")
+	err := LoadJson(l, buf)
+	assert.Nil(t, err)
+
+	// check count
+	keys := listKeys(l, "", false)
+	assert.ElementsMatch(t, keys, []string{
+		"http.server.port",
+		"http.server.multiconnections",
+		"http.server.servername",
+		"http.hosts.0",
+		"http.hosts.1",
+		"http.hosts.2.name",
+		"ratio",
+		"dotted.key",
+	})
+
+	// check values through a view
+	v := NewView(l, "http.server")
+	i, ok := v.GetInt("port")
+	assert.True(t, ok)
+	assert.EqualValues(t, 8080, i)
+
+	b, ok := v.GetBool("multiconnections")
+	assert.True(t, ok)
+	assert.False(t, b)
+
+	s, ok := v.GetString("servername")
+	assert.True(t, ok)
+	assert.Equal(t, "MyTestServer v0.9", s)
+
+	s, ok = l.GetString("http.hosts.2.name")
+	assert.True(t, ok)
+	assert.Equal(t, "c", s)
+
+	s, ok = l.GetString("ratio")
+	assert.True(t, ok)
+	assert.Equal(t, "1.5e3", s)
+
+	// try to load json to a read-only target
+	err = LoadJson(NewEmptyView(), bytes.NewBufferString("{}"))
+	assert.ErrorIs(t, err, ErrReadOnly)
+}
+
+func TestLoadJsonErrors(t *testing.T) {
+	l := NewLayer("test")
+	assert.NotNil(t, LoadJson(l, bytes.NewBufferString(`{"a": `)))
+	assert.NotNil(t, LoadJson(l, bytes.NewBufferString(`[1, 2]`)))
+	assert.NotNil(t, LoadJson(l, bytes.NewBufferString(`{} {}`)))
+	assert.Zero(t, len(listKeys(l, "", false)))
+}
+
+func TestSaveJson(t *testing.T) {
+	l := NewLayer("test")
+	l.SetString("http.server.port", "8080")
+	l.SetString("http.hosts.0", "a")
+	l.SetString("http.hosts.1", "b")
+	l.SetString("http.hosts.2.name", "c")
+	l.SetString("sparse.0", "x")
+	l.SetString("sparse.2", "y")
+	l.SetString("text", "<a&b>")
+
+	var buf bytes.Buffer
+	err := SaveJson(l, &buf)
+	assert.Nil(t, err)
+	assert.Equal(t, `{
+  "http": {
+    "hosts": [
+      "a",
+      "b",
+      {
+        "name": "c"
+      }
+    ],
+    "server": {
+      "port": "8080"
+    }
+  },
+  "sparse": {
+    "0": "x",
+    "2": "y"
+  },
+  "text": "<a&b>"
+}
+`, buf.String())
+
+	// load it back
+	l2 := NewLayer("test2")
+	err = LoadJson(l2, &buf)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, listKeys(l, "", false), listKeys(l2, "", false))
+	s, ok := l2.GetString("http.hosts.2.name")
+	assert.True(t, ok)
+	assert.Equal(t, "c", s)
+}
+
+func TestSaveJsonConflict(t *testing.T) {
+	// a key cannot have both a value and subkeys in json
+	l := NewLayer("test")
+	l.SetString("a.b", "1")
+	l.SetString("a.b.c", "2")
+
+	var buf bytes.Buffer
+	err := SaveJson(l, &buf)
+	assert.EqualError(t, err, `json: key "a.b" has both a value and subkeys`)
+
+	// empty source is saved as an empty object
+	buf.Reset()
+	err = SaveJson(NewEmptyView(), &buf)
+	assert.Nil(t, err)
+	assert.Equal(t, "{}\n", buf.String())
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Tree representation of the flat dotted key space, used when serializing to
+// hierarchical formats
+type keyTree struct {
+	value    string
+	hasValue bool
+	children map[string]*keyTree
+}
+
+// Build a key tree from all the keys of the viewable
+func buildKeyTree(viewable Viewable) *keyTree {
+	root := &keyTree{}
+	var keylist KeyList
+	viewable.ListKeys("", &keylist, false)
+	for _, key := range keylist.ToSlice() {
+		val, ok := viewable.GetString(key)
+		if !ok {
+			continue
+		}
+
+		// walk down the tree, create nodes on the way
+		node := root
+		for _, part := range strings.Split(key, ".") {
+			if node.children == nil {
+				node.children = map[string]*keyTree{}
+			}
+			child, ok := node.children[part]
+			if !ok {
+				child = &keyTree{}
+				node.children[part] = child
+			}
+			node = child
+		}
+		node.value = val
+		node.hasValue = true
+	}
+	return root
+}
+
+// Check that the node is either a value or a container, but not both
+func (t *keyTree) check(path string) error {
+	if t.hasValue && len(t.children) > 0 {
+		return fmt.Errorf("key %q has both a value and subkeys", path)
+	}
+	return nil
+}
+
+// Get the child keys in a stable order, numeric keys are sorted by value
+func (t *keyTree) sortedKeys() []string {
+	keys := make([]string, 0, len(t.children))
+	for k := range t.children {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, compareKeys)
+	return keys
+}
+
+// Test if the children of the node are indexed as 0, 1, ... n-1
+func (t *keyTree) isList() bool {
+	if len(t.children) == 0 {
+		return false
+	}
+	for i := 0; i < len(t.children); i++ {
+		if _, ok := t.children[strconv.Itoa(i)]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Compare two key parts, numbers are compared by value and precede names
+func compareKeys(a, b string) int {
+	an, aerr := strconv.ParseUint(a, 10, 64)
+	bn, berr := strconv.ParseUint(b, 10, 64)
+	switch {
+	case aerr == nil && berr == nil:
+		if an != bn {
+			if an < bn {
+				return -1
+			}
+			return 1
+		}
+	case aerr == nil:
+		return -1
+	case berr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// Join a key prefix and a subkey with a dot
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
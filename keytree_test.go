@@ -0,0 +1,43 @@
+package config
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyTree(t *testing.T) {
+	l := NewLayer("test")
+	l.SetString("list.0", "a")
+	l.SetString("list.1", "b")
+	l.SetString("list.10", "k")
+	l.SetString("list.2", "c")
+	l.SetString("obj.x", "1")
+
+	tree := buildKeyTree(l)
+	assert.Equal(t, []string{"list", "obj"}, tree.sortedKeys())
+	assert.False(t, tree.isList())
+
+	// list.10 breaks the continuous indexing
+	list := tree.children["list"]
+	assert.Equal(t, []string{"0", "1", "2", "10"}, list.sortedKeys())
+	assert.False(t, list.isList())
+	delete(list.children, "10")
+	assert.True(t, list.isList())
+
+	// leaf
+	leaf := tree.children["obj"].children["x"]
+	assert.True(t, leaf.hasValue)
+	assert.Equal(t, "1", leaf.value)
+	assert.False(t, leaf.isList())
+	assert.Nil(t, leaf.check("obj.x"))
+}
+
+func TestCompareKeys(t *testing.T) {
+	keys := []string{"b", "10", "a", "2", "02", "1"}
+	slices.SortFunc(keys, compareKeys)
+	assert.Equal(t, []string{"1", "02", "2", "10", "a", "b"}, keys)
+	assert.Equal(t, "a.b", joinKey("a", "b"))
+	assert.Equal(t, "b", joinKey("", "b"))
+}
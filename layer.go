@@ -1,24 +1,35 @@
 package config
 
 import (
+	"fmt"
+	"maps"
 	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 // Config layer storing key-value pairs in memory
 type Layer struct {
-	mx       sync.Mutex
-	name     string
-	values   map[string]string
-	writable bool
+	mx         sync.RWMutex
+	id         uint64 // creation order, the order of locking multiple layers
+	name       string
+	values     map[string]string
+	tombstones map[string]bool
+	writable   bool
+	subs       []*subscriber
+	observers  []*layerObserver
 }
 
+var layerIds atomic.Uint64
+
 // Create a layer with the given name
 func NewLayer(name string) *Layer {
 	return &Layer{
-		name:     name,
-		values:   map[string]string{},
-		writable: true,
+		id:         layerIds.Add(1),
+		name:       name,
+		values:     map[string]string{},
+		tombstones: map[string]bool{},
+		writable:   true,
 	}
 }
 
@@ -40,8 +51,8 @@ func (l *Layer) LockReadOnly() {
 // Check if the layer is writable
 func (l *Layer) IsWritable() bool {
 	// lock mutex
-	l.mx.Lock()
-	defer l.mx.Unlock()
+	l.mx.RLock()
+	defer l.mx.RUnlock()
 
 	return l.writable
 }
@@ -49,72 +60,240 @@ func (l *Layer) IsWritable() bool {
 // Get raw string value for the given key
 func (l *Layer) GetString(key string) (value string, found bool) {
 	// lock mutex
-	l.mx.Lock()
-	defer l.mx.Unlock()
+	l.mx.RLock()
+	defer l.mx.RUnlock()
 
 	ret, found := l.values[key]
 	return ret, found
 }
 
-// Delete all values from the layer
-func (l *Layer) Clear() {
+// Get raw string value for the given key, also report if the key is deleted by
+// a tombstone
+func (l *Layer) lookup(key string) (value string, found bool, deleted bool) {
 	// lock mutex
-	l.mx.Lock()
-	defer l.mx.Unlock()
+	l.mx.RLock()
+	defer l.mx.RUnlock()
+
+	ret, found := l.values[key]
+	return ret, found, l.tombstones[key]
+}
 
-	if !l.writable {
+// Check if the key is deleted by a tombstone. A tombstone hides the key in the
+// lower priority layers of a Config, see Config.DeleteValue
+func (l *Layer) IsDeleted(key string) bool {
+	// lock mutex
+	l.mx.RLock()
+	defer l.mx.RUnlock()
+
+	return l.tombstones[key]
+}
+
+// Delete value for the given key and leave a tombstone in its place
+func (l *Layer) tryDeleteWithTombstone(key string) error {
+	return l.update(func() []layerChange {
+		old := l.stateOf(key)
+		delete(l.values, key)
+		l.tombstones[key] = true
+		return []layerChange{{key, old, l.stateOf(key)}}
+	})
+}
+
+// Collect the tombstones of the layer into the set
+func (l *Layer) collectTombstones(out map[string]bool) {
+	// lock mutex
+	l.mx.RLock()
+	defer l.mx.RUnlock()
+
+	for k := range l.tombstones {
+		out[k] = true
+	}
+}
+
+func (l *Layer) errReadOnly() error {
+	return fmt.Errorf("%w: layer %q", ErrReadOnly, l.name)
+}
+
+// Delete all values from the layer, panics if the layer is read-only
+func (l *Layer) Clear() {
+	if err := l.TryClear(); err != nil {
 		panic("trying to clear a read-only layer")
 	}
-	l.values = map[string]string{}
 }
 
-// Set raw string value for the given key
-func (l *Layer) SetString(key, value string) {
-	// lock mutex
-	l.mx.Lock()
-	defer l.mx.Unlock()
+// Delete all values from the layer, returns an error wrapping ErrReadOnly if
+// the layer is read-only
+func (l *Layer) TryClear() error {
+	return l.update(func() []layerChange {
+		// collect changes only if someone listens
+		var changes []layerChange
+		if l.hasListeners() {
+			for k := range l.values {
+				changes = append(changes, layerChange{k, l.stateOf(k), keyState{}})
+			}
+			for k := range l.tombstones {
+				if _, ok := l.values[k]; !ok {
+					changes = append(changes, layerChange{k, l.stateOf(k), keyState{}})
+				}
+			}
+		}
+		l.values = map[string]string{}
+		l.tombstones = map[string]bool{}
+		return changes
+	})
+}
 
-	if !l.writable {
+// Set raw string value for the given key, panics if the layer is read-only
+func (l *Layer) SetString(key, value string) {
+	if err := l.TrySetString(key, value); err != nil {
 		panic("trying to write a read-only layer")
 	}
-	l.values[key] = value
 }
 
-// Delete value for the given key
+// Set raw string value for the given key, returns an error wrapping
+// ErrReadOnly if the layer is read-only
+func (l *Layer) TrySetString(key, value string) error {
+	return l.update(func() []layerChange {
+		old := l.stateOf(key)
+		l.values[key] = value
+		delete(l.tombstones, key)
+		return []layerChange{{key, old, l.stateOf(key)}}
+	})
+}
+
+// Delete value for the given key. The tombstone of the key is also removed, so
+// in a Config the lower priority layers supply the value again. Panics if the
+// layer is read-only
 func (l *Layer) DeleteValue(key string) {
+	if err := l.TryDeleteValue(key); err != nil {
+		panic("trying to delete from read-only layer")
+	}
+}
+
+// Delete value for the given key like DeleteValue, returns an error wrapping
+// ErrReadOnly if the layer is read-only
+func (l *Layer) TryDeleteValue(key string) error {
+	return l.update(func() []layerChange {
+		old := l.stateOf(key)
+		delete(l.values, key)
+		delete(l.tombstones, key)
+		return []layerChange{{key, old, l.stateOf(key)}}
+	})
+}
+
+// Replace all values of the layer at once with the given values, which are
+// copied. Readers see either the old or the new values, never a mix of them.
+// Tombstones are removed. Panics if the layer is read-only
+func (l *Layer) Replace(values map[string]string) {
+	if err := l.TryReplace(values); err != nil {
+		panic("trying to replace a read-only layer")
+	}
+}
+
+// Replace all values of the layer like Replace, returns an error wrapping
+// ErrReadOnly if the layer is read-only
+func (l *Layer) TryReplace(values map[string]string) error {
+	copied := make(map[string]string, len(values))
+	maps.Copy(copied, values)
+	return l.replaceValues(true, copied, map[string]bool{})
+}
+
+// Replace all values and tombstones of the layer at once with the ones of the
+// other layer. Readers see either the old or the new values, never a mix of
+// them. Panics if the layer is read-only
+func (l *Layer) ReplaceWith(other *Layer) {
+	if err := l.TryReplaceWith(other); err != nil {
+		panic("trying to replace a read-only layer")
+	}
+}
+
+// Replace all values and tombstones of the layer like ReplaceWith, returns an
+// error wrapping ErrReadOnly if the layer is read-only
+func (l *Layer) TryReplaceWith(other *Layer) error {
+	values, tombstones := other.copyState()
+	return l.replaceValues(true, values, tombstones)
+}
+
+// Get a copy of the values and tombstones of the layer
+func (l *Layer) copyState() (map[string]string, map[string]bool) {
 	// lock mutex
-	l.mx.Lock()
-	defer l.mx.Unlock()
+	l.mx.RLock()
+	defer l.mx.RUnlock()
 
-	if !l.writable {
-		panic("trying to delete from read-only layer")
+	return maps.Clone(l.values), maps.Clone(l.tombstones)
+}
+
+// Swap the values and tombstones of the layer to the given maps, which are
+// owned by the layer after the call. The writable flag is checked only if
+// checkWritable is true
+func (l *Layer) replaceValues(checkWritable bool, values map[string]string, tombstones map[string]bool) error {
+	return l.modify(checkWritable, func() []layerChange {
+		return l.swapValues(values, tombstones)
+	})
+}
+
+// Swap the values and tombstones like replaceValues and return the changes.
+// Must be called with the mutex locked
+func (l *Layer) swapValues(values map[string]string, tombstones map[string]bool) []layerChange {
+	// collect changes only if someone listens
+	var changes []layerChange
+	if l.hasListeners() {
+		keys := map[string]bool{}
+		for _, m := range []map[string]string{l.values, values} {
+			for k := range m {
+				keys[k] = true
+			}
+		}
+		for _, m := range []map[string]bool{l.tombstones, tombstones} {
+			for k := range m {
+				keys[k] = true
+			}
+		}
+		for k := range keys {
+			value, found := values[k]
+			next := keyState{value, found, tombstones[k]}
+			if prev := l.stateOf(k); prev != next {
+				changes = append(changes, layerChange{k, prev, next})
+			}
+		}
 	}
-	delete(l.values, key)
+	l.values = values
+	l.tombstones = tombstones
+	return changes
 }
 
-// List keys, see Viewable for detail
-func (l *Layer) ListKeys(prefix string, out *KeyList, direct bool) {
-	// ensure trailing dot
+// Append a trailing dot to a non-empty key prefix
+func normalizePrefix(prefix string) string {
 	if !strings.HasSuffix(prefix, ".") && prefix != "" {
 		prefix += "."
 	}
+	return prefix
+}
+
+// Strip the key to its first part if only direct subkeys are listed
+func directKey(key string, direct bool) string {
+	if direct {
+		idx := strings.IndexRune(key, '.')
+		if idx >= 0 {
+			key = key[:idx]
+		}
+	}
+	return key
+}
+
+// List keys, see Viewable for detail
+func (l *Layer) ListKeys(prefix string, out *KeyList, direct bool) {
+	// ensure trailing dot
+	prefix = normalizePrefix(prefix)
 	prefixlen := len(prefix)
 
 	// lock mutex
-	l.mx.Lock()
-	defer l.mx.Unlock()
+	l.mx.RLock()
+	defer l.mx.RUnlock()
 
 	// go through keys
 	for k := range l.values {
 		if strings.HasPrefix(k, prefix) {
-			k = k[prefixlen:]
-			if direct {
-				idx := strings.IndexRune(k, '.')
-				if idx >= 0 {
-					k = k[:idx]
-				}
-			}
-			out.addKey(k)
+			out.addKey(directKey(k[prefixlen:], direct))
 		}
 	}
 }
@@ -134,3 +134,70 @@ func TestLayerDelete(t *testing.T) {
 	})
 	assert.Equal(t, 2, len(l.values))
 }
+
+func TestLayerTryWrite(t *testing.T) {
+	l := NewLayer("testlayer")
+	assert.Nil(t, l.TrySetString("key1", "value1"))
+	assert.Nil(t, l.TrySetString("key2", "value2"))
+	assert.Nil(t, l.TryDeleteValue("key1"))
+	_, ok := l.GetString("key1")
+	assert.False(t, ok)
+
+	// read-only layer reports error and keeps its values
+	l.LockReadOnly()
+	err := l.TrySetString("key1", "value1")
+	assert.ErrorIs(t, err, ErrReadOnly)
+	assert.Equal(t, `config: not writable: layer "testlayer"`, err.Error())
+	assert.ErrorIs(t, l.TryDeleteValue("key2"), ErrReadOnly)
+	assert.ErrorIs(t, l.TryClear(), ErrReadOnly)
+	assert.Equal(t, map[string]string{"key2": "value2"}, l.values)
+}
+
+func TestLayerReplace(t *testing.T) {
+	l := NewLayer("testlayer")
+	l.SetString("a", "1")
+	l.SetString("b", "2")
+	var events []ChangeEvent
+	l.Subscribe("", collectEvents(&events))
+
+	// replace with a map, the map is copied
+	values := map[string]string{"b": "2", "c": "3"}
+	l.Replace(values)
+	values["d"] = "4"
+	assert.Equal(t, map[string]string{"b": "2", "c": "3"}, l.values)
+	assert.ElementsMatch(t, []ChangeEvent{
+		{"a", "1", "", true, false, l},
+		{"c", "", "3", false, true, l},
+	}, events)
+
+	// replace with another layer, tombstones are also copied
+	other := NewLayer("other")
+	other.SetString("x", "y")
+	conf := NewConfig()
+	conf.AddWritableLayer(other, 0)
+	conf.DeleteValue("c")
+	l.ReplaceWith(other)
+	assert.Equal(t, map[string]string{"x": "y"}, l.values)
+	assert.True(t, l.IsDeleted("c"))
+	other.SetString("x", "z")
+	s, _ := l.GetString("x")
+	assert.Equal(t, "y", s)
+
+	// replace with nil map
+	l2 := NewLayer("l2")
+	l2.Replace(nil)
+	l2.SetString("a", "b")
+	assert.Equal(t, map[string]string{"a": "b"}, l2.values)
+
+	// read-only layer
+	l.LockReadOnly()
+	assert.ErrorIs(t, l.TryReplace(nil), ErrReadOnly)
+	assert.ErrorIs(t, l.TryReplaceWith(other), ErrReadOnly)
+	assert.Panics(t, func() {
+		l.Replace(nil)
+	})
+	assert.Panics(t, func() {
+		l.ReplaceWith(other)
+	})
+	assert.Equal(t, map[string]string{"x": "y"}, l.values)
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Default delimiter of list values
+const DefaultListDelimiter = ","
+
+// Create a copy of the view that uses the given delimiter for list values.
+// Subviews inherit the delimiter. An empty delimiter restores the default
+func (view View) WithListDelimiter(delimiter string) View {
+	view.listsep = delimiter
+	return view
+}
+
+func (view View) listDelimiter() string {
+	if view.listsep == "" {
+		return DefaultListDelimiter
+	}
+	return view.listsep
+}
+
+// Split a delimited list value, items are trimmed
+func (view View) splitList(s string) []string {
+	if strings.TrimSpace(s) == "" {
+		return []string{}
+	}
+	ret := strings.Split(s, view.listDelimiter())
+	for i := range ret {
+		ret[i] = strings.TrimSpace(ret[i])
+	}
+	return ret
+}
+
+// Get string list value for the given key. Lists are either stored under
+// indexed subkeys (key.0, key.1, ...), which are sorted numerically, or as a
+// single delimited value like "a,b,c". Indexed subkeys take precedence
+func (view View) GetStringList(key string) (result []string, found bool) {
+	// indexed form
+	indices := listIndices(view, key)
+	if len(indices) > 0 {
+		sub := view.SubViewReadOnly(key)
+		ret := make([]string, 0, len(indices))
+		for _, i := range indices {
+			if s, ok := sub.GetString(strconv.Itoa(i)); ok {
+				ret = append(ret, s)
+			}
+		}
+		return ret, true
+	}
+
+	// delimited form
+	sv, ok := view.GetString(key)
+	if !ok {
+		return nil, false
+	}
+	return view.splitList(sv), true
+}
+
+// Set string list value for the given key in indexed form. Previous list
+// items under the key are deleted
+func (view View) SetStringList(key string, values []string) {
+	sub := view.SubView(key)
+	for _, i := range listIndices(view, key) {
+		sub.DeleteValue(strconv.Itoa(i))
+	}
+	if _, ok := view.GetString(key); ok {
+		view.DeleteValue(key)
+	}
+	for i, v := range values {
+		sub.SetString(strconv.Itoa(i), v)
+	}
+}
+
+// Get int list value for the given key, see GetStringList for the forms.
+// Reports not found either if any of the items is not convertible to integer
+func (view View) GetIntList(key string) (result []int64, found bool) {
+	items, ok := view.GetStringList(key)
+	if !ok {
+		return nil, false
+	}
+	ret := make([]int64, len(items))
+	for i, item := range items {
+		v, err := parseInt64(item)
+		if err != nil {
+			return nil, false
+		}
+		ret[i] = v
+	}
+	return ret, true
+}
+
+// Set int list value for the given key in indexed form
+func (view View) SetIntList(key string, values []int64) {
+	items := make([]string, len(values))
+	for i, v := range values {
+		items[i] = strconv.FormatInt(v, 10)
+	}
+	view.SetStringList(key, items)
+}
+
+// Get the values of the direct subkeys of the given key as a map. Subkeys
+// without a value (having only nested subkeys) are left out
+func (view View) GetStringMap(key string) (result map[string]string, found bool) {
+	var keys KeyList
+	view.ListKeys(key, &keys, true)
+	if len(keys.v) == 0 {
+		return nil, false
+	}
+	sub := view.SubViewReadOnly(key)
+	ret := make(map[string]string, len(keys.v))
+	for _, k := range keys.ToSlice() {
+		if s, ok := sub.GetString(k); ok {
+			ret[k] = s
+		}
+	}
+	return ret, true
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStringList(t *testing.T) {
+	l := NewLayer("test")
+	l.SetString("indexed.0", "a")
+	l.SetString("indexed.10", "c")
+	l.SetString("indexed.2", "b")
+	l.SetString("indexed.x", "ignored")
+	l.SetString("delimited", "a, b ,c")
+	l.SetString("semicolon", "a;b,c")
+	l.SetString("empty", "")
+	v := NewView(l, "")
+
+	list, ok := v.GetStringList("indexed")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"a", "b", "c"}, list)
+
+	list, ok = v.GetStringList("delimited")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"a", "b", "c"}, list)
+
+	list, ok = v.GetStringList("empty")
+	assert.True(t, ok)
+	assert.Equal(t, []string{}, list)
+
+	_, ok = v.GetStringList("missing")
+	assert.False(t, ok)
+
+	// custom delimiter, inherited by subviews
+	sv := v.WithListDelimiter(";").SubView("")
+	list, ok = sv.GetStringList("semicolon")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"a", "b,c"}, list)
+
+	list, ok = sv.WithListDelimiter("").GetStringList("semicolon")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"a;b", "c"}, list)
+
+	// set replaces both forms
+	v.SetStringList("indexed", []string{"x", "y"})
+	assert.ElementsMatch(t, listKeys(l, "indexed", false), []string{"0", "1", "x"})
+	v.SetStringList("delimited", []string{"z"})
+	_, ok = l.GetString("delimited")
+	assert.False(t, ok)
+	list, ok = v.GetStringList("delimited")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"z"}, list)
+}
+
+func TestIntListAndMap(t *testing.T) {
+	l := NewLayer("test")
+	l.SetString("ports", "80, 0x1bb")
+	l.SetString("bad", "1,x")
+	l.SetString("labels.env", "prod")
+	l.SetString("labels.team", "core")
+	l.SetString("labels.nested.x", "1")
+	v := NewView(l, "")
+
+	ints, ok := v.GetIntList("ports")
+	assert.True(t, ok)
+	assert.Equal(t, []int64{80, 443}, ints)
+
+	_, ok = v.GetIntList("bad")
+	assert.False(t, ok)
+
+	_, ok = v.GetIntList("missing")
+	assert.False(t, ok)
+
+	v.SetIntList("ports", []int64{1, 2, 3})
+	ints, ok = v.GetIntList("ports")
+	assert.True(t, ok)
+	assert.Equal(t, []int64{1, 2, 3}, ints)
+
+	m, ok := v.GetStringMap("labels")
+	assert.True(t, ok)
+	assert.Equal(t, map[string]string{"env": "prod", "team": "core"}, m)
+
+	_, ok = v.GetStringMap("missing")
+	assert.False(t, ok)
+
+	// delimited values in structs
+	var out struct {
+		Ports []int    `config:"csv"`
+		Names []string `config:"names"`
+	}
+	l.SetString("csv", "1,2")
+	l.SetString("names", "a")
+	err := Unmarshal(v, &out)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{1, 2}, out.Ports)
+	assert.Equal(t, []string{"a"}, out.Names)
+
+	l.SetString("csv", "1,x")
+	err = Unmarshal(v, &out)
+	assert.EqualError(t, err, `config: cannot unmarshal "1,x" at key "csv" into []int: strconv.ParseInt: parsing "x": invalid syntax`)
+}
+
+func TestListIniRoundTrip(t *testing.T) {
+	l := NewLayer("test")
+	v := NewView(l, "")
+	v.SetStringList("hosts", []string{"a=1", "b\nc", "d"})
+	v.SetString("csv", "x,y,z")
+	v.SetString("labels.env", "prod")
+
+	var buf bytes.Buffer
+	SaveIni(l, &buf)
+	l2 := NewLayer("test2")
+	err := LoadIni(l2, bufio.NewReader(&buf))
+	assert.Nil(t, err)
+	v2 := NewView(l2, "")
+
+	list, ok := v2.GetStringList("hosts")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"a=1", "b\nc", "d"}, list)
+
+	list, ok = v2.GetStringList("csv")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"x", "y", "z"}, list)
+
+	m, ok := v2.GetStringMap("labels")
+	assert.True(t, ok)
+	assert.Equal(t, map[string]string{"env": "prod"}, m)
+}
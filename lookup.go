@@ -0,0 +1,127 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Error reported when a value cannot be converted to the requested type
+type ConversionError struct {
+	Key   string // full key of the value
+	Value string // raw string value
+	Type  string // name of the requested type
+	Layer *Layer // layer that supplied the value, nil if unknown
+	Err   error  // reason of the failure
+}
+
+func (e *ConversionError) Error() string {
+	from := ""
+	if e.Layer != nil {
+		from = fmt.Sprintf(" from layer %q", e.Layer.Name())
+	}
+	return fmt.Sprintf("config: cannot convert %q at key %q%s to %s: %v", e.Value, e.Key, from, e.Type, e.Err)
+}
+
+func (e *ConversionError) Unwrap() error {
+	return e.Err
+}
+
+// Find the layer that supplies the value for the given key, nil if unknown
+func sourceLayer(viewable Viewable, key string) *Layer {
+	switch v := viewable.(type) {
+	case *Layer:
+		return v
+	case *Config:
+		return v.layerOf(key)
+	case View:
+		return sourceLayer(v.viewable, v.deriveKey(key))
+	default:
+		return nil
+	}
+}
+
+// Look up and convert the value for the given key
+func lookupValue[T any](view View, key, typename string, parse func(string) (T, error)) (T, error) {
+	var zero T
+	sv, ok, err := view.getExpanded(key)
+	if err != nil {
+		return zero, err
+	}
+	if !ok {
+		return zero, fmt.Errorf("%w: %s", ErrNotFound, view.deriveKey(key))
+	}
+	ret, err := parse(sv)
+	if err != nil {
+		return zero, &ConversionError{
+			Key:   view.deriveKey(key),
+			Value: sv,
+			Type:  typename,
+			Layer: sourceLayer(view.viewable, view.deriveKey(key)),
+			Err:   err,
+		}
+	}
+	return ret, nil
+}
+
+// Get string value for the given key, returns an error wrapping ErrNotFound if
+// there is no value, or a *InterpolationError if the references in the value
+// cannot be resolved
+func (view View) LookupString(key string) (string, error) {
+	return lookupValue(view, key, "string", func(s string) (string, error) {
+		return s, nil
+	})
+}
+
+// Get int value for the given key. Returns an error wrapping ErrNotFound if
+// there is no value, or a *ConversionError if the value is malformed
+func (view View) LookupInt(key string) (int64, error) {
+	return lookupValue(view, key, "int", parseInt64)
+}
+
+// Get unsigned int value for the given key. Returns an error wrapping
+// ErrNotFound if there is no value, or a *ConversionError if the value is
+// malformed
+func (view View) LookupUint(key string) (uint64, error) {
+	return lookupValue(view, key, "uint", parseUint64)
+}
+
+// Get float value for the given key. Returns an error wrapping ErrNotFound if
+// there is no value, or a *ConversionError if the value is malformed
+func (view View) LookupFloat(key string) (float64, error) {
+	return lookupValue(view, key, "float", parseFloat64)
+}
+
+// Get bool value for the given key. Returns an error wrapping ErrNotFound if
+// there is no value, or a *ConversionError if the value is malformed
+func (view View) LookupBool(key string) (bool, error) {
+	return lookupValue(view, key, "bool", strconv.ParseBool)
+}
+
+// Get duration value for the given key, see GetDuration for the syntax.
+// Returns an error wrapping ErrNotFound if there is no value, or a
+// *ConversionError if the value is malformed
+func (view View) LookupDuration(key string) (time.Duration, error) {
+	return lookupValue(view, key, "duration", parseDuration)
+}
+
+// Get byte size value for the given key, see ParseByteSize for the syntax.
+// Returns an error wrapping ErrNotFound if there is no value, or a
+// *ConversionError if the value is malformed
+func (view View) LookupByteSize(key string) (ByteSize, error) {
+	return lookupValue(view, key, "byte size", ParseByteSize)
+}
+
+// Get percentage value for the given key, see ParsePercent for the syntax.
+// Returns an error wrapping ErrNotFound if there is no value, or a
+// *ConversionError if the value is malformed
+func (view View) LookupPercent(key string) (Percent, error) {
+	return lookupValue(view, key, "percentage", ParsePercent)
+}
+
+// Get rate value for the given key, see ParseRate for the syntax. Returns an
+// error wrapping ErrNotFound if there is no value, or a *ConversionError if
+// the value is malformed
+func (view View) LookupRate(key string) (Rate, error) {
+	return lookupValue(view, key, "rate", ParseRate)
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookup(t *testing.T) {
+	l := NewLayer("test")
+	l.SetString("http.port", "8080")
+	l.SetString("http.typo", "80a")
+	l.SetString("http.ratio", "0.5")
+	l.SetString("http.enabled", "yes")
+	l.SetString("http.timeout", "30")
+	v := NewView(l, "http")
+
+	// found values
+	s, err := v.LookupString("port")
+	assert.Nil(t, err)
+	assert.Equal(t, "8080", s)
+
+	i, err := v.LookupInt("port")
+	assert.Nil(t, err)
+	assert.EqualValues(t, 8080, i)
+
+	u, err := v.LookupUint("port")
+	assert.Nil(t, err)
+	assert.EqualValues(t, 8080, u)
+
+	f, err := v.LookupFloat("ratio")
+	assert.Nil(t, err)
+	assert.Equal(t, 0.5, f)
+
+	d, err := v.LookupDuration("timeout")
+	assert.Nil(t, err)
+	assert.Equal(t, 30*time.Second, d)
+
+	// missing values
+	_, err = v.LookupString("missing")
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.EqualError(t, err, "config: key not found: http.missing")
+
+	_, err = v.LookupInt("missing")
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	// malformed values
+	_, err = v.LookupInt("typo")
+	var cerr *ConversionError
+	assert.True(t, errors.As(err, &cerr))
+	assert.False(t, errors.Is(err, ErrNotFound))
+	assert.True(t, errors.Is(err, strconv.ErrSyntax))
+	assert.Equal(t, "http.typo", cerr.Key)
+	assert.Equal(t, "80a", cerr.Value)
+	assert.Equal(t, "int", cerr.Type)
+	assert.Equal(t, l, cerr.Layer)
+	assert.EqualError(t, err, `config: cannot convert "80a" at key "http.typo" from layer "test" to int: strconv.ParseInt: parsing "80a": invalid syntax`)
+
+	_, err = v.LookupBool("enabled")
+	assert.True(t, errors.As(err, &cerr))
+	assert.Equal(t, "bool", cerr.Type)
+
+	_, err = v.LookupUint("ratio")
+	assert.True(t, errors.As(err, &cerr))
+	assert.Equal(t, "uint", cerr.Type)
+
+	_, err = v.LookupFloat("enabled")
+	assert.True(t, errors.As(err, &cerr))
+	assert.Equal(t, "float", cerr.Type)
+
+	_, err = v.LookupDuration("enabled")
+	assert.True(t, errors.As(err, &cerr))
+	assert.Equal(t, "duration", cerr.Type)
+
+	// unknown source
+	_, err = NewView(staticViewable{"k": "x"}, "").LookupInt("k")
+	assert.True(t, errors.As(err, &cerr))
+	assert.Nil(t, cerr.Layer)
+	assert.EqualError(t, err, `config: cannot convert "x" at key "k" to int: strconv.ParseInt: parsing "x": invalid syntax`)
+}
+
+// Minimal read-only viewable for testing
+type staticViewable map[string]string
+
+func (v staticViewable) IsWritable() bool {
+	return false
+}
+
+func (v staticViewable) GetString(key string) (string, bool) {
+	s, ok := v[key]
+	return s, ok
+}
+
+func (v staticViewable) SetString(key, value string) {
+	panic("static viewable is not writable")
+}
+
+func (v staticViewable) DeleteValue(key string) {
+	panic("static viewable is not writable")
+}
+
+func (v staticViewable) ListKeys(prefix string, out *KeyList, direct bool) {}
+
+func TestLookupSourceLayer(t *testing.T) {
+	defaults := NewLayer("defaults")
+	defaults.SetString("http.port", "8080")
+	defaults.SetString("http.timeout", "30s")
+	overrides := NewLayer("overrides")
+	overrides.SetString("http.port", "80a")
+
+	conf := NewConfig()
+	conf.AddLayer(defaults, 0)
+	conf.AddLayer(overrides, 10)
+
+	// the layer that supplied the malformed value is reported through views
+	v := NewView(NewView(conf, "http"), "")
+	_, err := v.LookupInt("port")
+	var cerr *ConversionError
+	assert.True(t, errors.As(err, &cerr))
+	assert.Equal(t, overrides, cerr.Layer)
+	assert.Equal(t, "http.port", cerr.Key)
+
+	_, err = v.LookupInt("timeout")
+	assert.True(t, errors.As(err, &cerr))
+	assert.Equal(t, defaults, cerr.Layer)
+
+	// unit lookups report conversion errors too
+	_, err = v.LookupByteSize("port")
+	assert.True(t, errors.As(err, &cerr))
+	assert.Equal(t, "byte size", cerr.Type)
+	assert.Equal(t, overrides, cerr.Layer)
+
+	assert.Nil(t, conf.layerOf("missing"))
+}
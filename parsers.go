@@ -0,0 +1,181 @@
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+type parserEntry struct {
+	typed   any                       // func(string) (T, error)
+	untyped func(string) (any, error) // same parser returning any
+}
+
+var parsers = struct {
+	mx sync.RWMutex
+	m  map[reflect.Type]parserEntry
+	f  map[reflect.Type]func(any) string // formatters used by Marshal
+}{
+	m: map[reflect.Type]parserEntry{},
+	f: map[reflect.Type]func(any) string{},
+}
+
+func typeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// Register a parser used by Get, GetOr and Lookup (and also by Unmarshal) to
+// convert raw string values to type T. Registering a parser for a type again
+// replaces the previous one. Types implementing encoding.TextUnmarshaler don't
+// need a parser, but a registered one takes precedence. Marshal does not use
+// the parser, register a formatter with RegisterFormatter if the type's
+// default form (see Marshal) is not accepted by the parser
+func RegisterParser[T any](parse func(string) (T, error)) {
+	// lock registry
+	parsers.mx.Lock()
+	defer parsers.mx.Unlock()
+
+	parsers.m[typeOf[T]()] = parserEntry{
+		typed: parse,
+		untyped: func(s string) (any, error) {
+			return parse(s)
+		},
+	}
+}
+
+// Register a formatter used by Marshal to convert values of type T to raw
+// string values. Registering a formatter for a type again replaces the
+// previous one. A registered formatter takes precedence over
+// encoding.TextMarshaler and fmt.Stringer
+func RegisterFormatter[T any](format func(T) string) {
+	// lock registry
+	parsers.mx.Lock()
+	defer parsers.mx.Unlock()
+
+	parsers.f[typeOf[T]()] = func(v any) string {
+		return format(v.(T))
+	}
+}
+
+// Get the registered formatter for the type
+func formatterOf(t reflect.Type) (func(any) string, bool) {
+	// lock registry
+	parsers.mx.RLock()
+	defer parsers.mx.RUnlock()
+
+	f, ok := parsers.f[t]
+	return f, ok
+}
+
+// Get the registered parser for the type
+func parserOf(t reflect.Type) (parserEntry, bool) {
+	// lock registry
+	parsers.mx.RLock()
+	defer parsers.mx.RUnlock()
+
+	p, ok := parsers.m[t]
+	return p, ok
+}
+
+// Get the parser for type T, falls back to encoding.TextUnmarshaler
+func parserFor[T any]() (func(string) (T, error), bool) {
+	t := typeOf[T]()
+	if p, ok := parserOf(t); ok {
+		return p.typed.(func(string) (T, error)), true
+	}
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return func(s string) (T, error) {
+			var ret T
+			err := any(&ret).(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+			return ret, err
+		}, true
+	}
+	return nil, false
+}
+
+// Get the value for the given key of any viewable, converted to type T.
+// Returns an error wrapping ErrNotFound if there is no value, or a
+// *ConversionError if the value is malformed. See RegisterParser for the
+// supported types
+func Lookup[T any](viewable Viewable, key string) (T, error) {
+	parse, ok := parserFor[T]()
+	if !ok {
+		var zero T
+		return zero, fmt.Errorf("config: no parser registered for type %s", typeOf[T]())
+	}
+	return lookupValue(NewView(viewable, ""), key, typeOf[T]().String(), parse)
+}
+
+// Get the value for the given key of any viewable, converted to type T.
+// Reports not found either if value is not convertible to T
+func Get[T any](viewable Viewable, key string) (result T, found bool) {
+	ret, err := Lookup[T](viewable, key)
+	return ret, err == nil
+}
+
+// Get the value for the given key of any viewable, converted to type T. Returns
+// def if there is no value or it is not convertible to T
+func GetOr[T any](viewable Viewable, key string, def T) T {
+	ret, err := Lookup[T](viewable, key)
+	if err != nil {
+		return def
+	}
+	return ret
+}
+
+func parseInt64(s string) (int64, error) {
+	return strconv.ParseInt(s, 0, 64)
+}
+
+func parseUint64(s string) (uint64, error) {
+	return strconv.ParseUint(s, 0, 64)
+}
+
+func parseFloat64(s string) (float64, error) {
+	return strconv.ParseFloat(s, 64)
+}
+
+// Register parser for a sized signed integer type
+func registerIntParser[T int | int8 | int16 | int32](bits int) {
+	RegisterParser(func(s string) (T, error) {
+		v, err := strconv.ParseInt(s, 0, bits)
+		return T(v), err
+	})
+}
+
+// Register parser for a sized unsigned integer type
+func registerUintParser[T uint | uint8 | uint16 | uint32 | uintptr](bits int) {
+	RegisterParser(func(s string) (T, error) {
+		v, err := strconv.ParseUint(s, 0, bits)
+		return T(v), err
+	})
+}
+
+func init() {
+	RegisterParser(func(s string) (string, error) {
+		return s, nil
+	})
+	RegisterParser(strconv.ParseBool)
+	RegisterParser(parseInt64)
+	registerIntParser[int](strconv.IntSize)
+	registerIntParser[int8](8)
+	registerIntParser[int16](16)
+	registerIntParser[int32](32)
+	RegisterParser(parseUint64)
+	registerUintParser[uint](strconv.IntSize)
+	registerUintParser[uint8](8)
+	registerUintParser[uint16](16)
+	registerUintParser[uint32](32)
+	registerUintParser[uintptr](strconv.IntSize)
+	RegisterParser(parseFloat64)
+	RegisterParser(func(s string) (float32, error) {
+		v, err := strconv.ParseFloat(s, 32)
+		return float32(v), err
+	})
+	RegisterParser(parseDuration)
+	RegisterParser(ParseByteSize)
+	RegisterParser(ParsePercent)
+	RegisterParser(ParseRate)
+}
@@ -0,0 +1,212 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testLogLevel int
+
+func parseTestLogLevel(s string) (testLogLevel, error) {
+	switch s {
+	case "debug":
+		return 0, nil
+	case "info":
+		return 1, nil
+	case "error":
+		return 2, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q", s)
+}
+
+func formatTestLogLevel(l testLogLevel) string {
+	return [...]string{"debug", "info", "error"}[l]
+}
+
+// Restore the parser and formatter of type T as they are now when the test
+// finishes, removing them if none is registered
+func restoreRegistry[T any](t *testing.T) {
+	typ := typeOf[T]()
+	parsers.mx.RLock()
+	p, pok := parsers.m[typ]
+	f, fok := parsers.f[typ]
+	parsers.mx.RUnlock()
+
+	t.Cleanup(func() {
+		parsers.mx.Lock()
+		defer parsers.mx.Unlock()
+		if pok {
+			parsers.m[typ] = p
+		} else {
+			delete(parsers.m, typ)
+		}
+		if fok {
+			parsers.f[typ] = f
+		} else {
+			delete(parsers.f, typ)
+		}
+	})
+}
+
+func TestGetBuiltin(t *testing.T) {
+	l := NewLayer("test")
+	l.SetString("int", "0x10")
+	l.SetString("small", "300")
+	l.SetString("bool", "true")
+	l.SetString("float", "2.5")
+	l.SetString("duration", "90")
+	l.SetString("size", "1KiB")
+	l.SetString("text", "abc")
+
+	// same conversion rules as the View getters
+	i, ok := Get[int64](l, "int")
+	assert.True(t, ok)
+	assert.EqualValues(t, 16, i)
+
+	vi, vok := NewView(l, "").GetInt("int")
+	assert.Equal(t, vok, ok)
+	assert.Equal(t, vi, i)
+
+	n, ok := Get[int](l, "int")
+	assert.True(t, ok)
+	assert.Equal(t, 16, n)
+
+	_, ok = Get[int8](l, "small")
+	assert.False(t, ok)
+
+	u8, ok := Get[uint16](l, "small")
+	assert.True(t, ok)
+	assert.EqualValues(t, 300, u8)
+
+	b, ok := Get[bool](l, "bool")
+	assert.True(t, ok)
+	assert.True(t, b)
+
+	f, ok := Get[float32](l, "float")
+	assert.True(t, ok)
+	assert.EqualValues(t, 2.5, f)
+
+	d, ok := Get[time.Duration](l, "duration")
+	assert.True(t, ok)
+	assert.Equal(t, 90*time.Second, d)
+
+	size, ok := Get[ByteSize](l, "size")
+	assert.True(t, ok)
+	assert.Equal(t, KiB, size)
+
+	s, ok := Get[string](l, "text")
+	assert.True(t, ok)
+	assert.Equal(t, "abc", s)
+
+	// defaults
+	assert.Equal(t, 5, GetOr(l, "missing", 5))
+	assert.Equal(t, 5, GetOr(l, "text", 5))
+	assert.Equal(t, 16, GetOr(l, "int", 5))
+
+	// errors
+	_, err := Lookup[int](l, "missing")
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	_, err = Lookup[int](l, "text")
+	var cerr *ConversionError
+	assert.True(t, errors.As(err, &cerr))
+	assert.Equal(t, "int", cerr.Type)
+	assert.Equal(t, l, cerr.Layer)
+
+	_, err = Lookup[chan int](l, "text")
+	assert.EqualError(t, err, "config: no parser registered for type chan int")
+	_, ok = Get[chan int](l, "text")
+	assert.False(t, ok)
+}
+
+func TestGetCustom(t *testing.T) {
+	l := NewLayer("test")
+	l.SetString("ip", "10.0.0.1")
+	l.SetString("url", "https://example.com/path")
+	l.SetString("level", "info")
+	l.SetString("badlevel", "verbose")
+
+	// text unmarshalers work without registration
+	ip, ok := Get[net.IP](l, "ip")
+	assert.True(t, ok)
+	assert.Equal(t, net.IPv4(10, 0, 0, 1), ip)
+
+	// register parsers, leave the registry as it was
+	restoreRegistry[url.URL](t)
+	restoreRegistry[testLogLevel](t)
+	RegisterParser(func(s string) (url.URL, error) {
+		u, err := url.Parse(s)
+		if err != nil {
+			return url.URL{}, err
+		}
+		return *u, nil
+	})
+	RegisterParser(parseTestLogLevel)
+	RegisterFormatter(formatTestLogLevel)
+
+	u, ok := Get[url.URL](l, "url")
+	assert.True(t, ok)
+	assert.Equal(t, "example.com", u.Host)
+
+	level := GetOr(l, "level", testLogLevel(0))
+	assert.Equal(t, testLogLevel(1), level)
+
+	_, err := Lookup[testLogLevel](l, "badlevel")
+	var cerr *ConversionError
+	assert.True(t, errors.As(err, &cerr))
+	assert.Equal(t, "config.testLogLevel", cerr.Type)
+	assert.EqualError(t, cerr.Err, `unknown log level "verbose"`)
+
+	// registered parsers are used by Unmarshal too
+	var out struct {
+		URL   url.URL      `config:"url"`
+		Level testLogLevel `config:"level"`
+	}
+	err = Unmarshal(NewView(l, ""), &out)
+	assert.Nil(t, err)
+	assert.Equal(t, "/path", out.URL.Path)
+	assert.Equal(t, testLogLevel(1), out.Level)
+
+	// and Marshal writes them in string form
+	l2 := NewLayer("test2")
+	err = Marshal(&out, NewView(l2, ""))
+	assert.Nil(t, err)
+	s, ok := l2.GetString("url")
+	assert.True(t, ok)
+	assert.Equal(t, "https://example.com/path", s)
+	s, ok = l2.GetString("level")
+	assert.True(t, ok)
+	assert.Equal(t, "info", s)
+
+	// and the written values load back the same
+	var back struct {
+		URL   url.URL      `config:"url"`
+		Level testLogLevel `config:"level"`
+	}
+	err = Unmarshal(NewView(l2, ""), &back)
+	assert.Nil(t, err)
+	assert.Equal(t, out, back)
+}
+
+func TestRegisterParserOverride(t *testing.T) {
+	l := NewLayer("test")
+	l.SetString("int", "7")
+
+	// registering again replaces the built-in parser
+	t.Run("override", func(t *testing.T) {
+		restoreRegistry[int](t)
+		RegisterParser(func(s string) (int, error) {
+			return 42, nil
+		})
+		assert.Equal(t, 42, GetOr(l, "int", 0))
+	})
+
+	// and the test restored it
+	assert.Equal(t, 7, GetOr(l, "int", 0))
+}
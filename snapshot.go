@@ -0,0 +1,117 @@
+package config
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Immutable point-in-time copy of the merged values of a config. Reading it
+// needs no locking, so a request handler can read a consistent set of values
+// for its whole lifetime. Implements Viewable, but it is never writable
+type Snapshot struct {
+	values      map[string]string
+	interpolate bool
+}
+
+// Take a snapshot of the effective values of the config. All layers are
+// locked while copying, so the snapshot shows the values of every layer at the
+// same point in time, and a layer reloaded with Layer.Replace is seen either
+// completely before or completely after the reload. If interpolation is
+// enabled in the config, references are resolved within the snapshot
+func (c *Config) Snapshot() *Snapshot {
+	items := c.loadItems()
+
+	// lock the layers in creation order, so that concurrent snapshots of
+	// configs sharing layers cannot deadlock
+	layers := make([]*Layer, 0, len(items))
+	for _, item := range items {
+		if !slices.Contains(layers, item.layer) {
+			layers = append(layers, item.layer)
+		}
+	}
+	slices.SortFunc(layers, func(a, b *Layer) int {
+		return cmp.Compare(a.id, b.id)
+	})
+	for _, l := range layers {
+		l.mx.RLock()
+		defer l.mx.RUnlock()
+	}
+
+	// merge the layers from the highest priority, skip keys already set or
+	// deleted by a tombstone
+	values := map[string]string{}
+	deleted := map[string]bool{}
+	for _, item := range items {
+		for k, v := range item.layer.values {
+			if _, ok := values[k]; !ok && !deleted[k] {
+				values[k] = v
+			}
+		}
+		for k := range item.layer.tombstones {
+			deleted[k] = true
+		}
+	}
+	return &Snapshot{values, c.interpolation.Load()}
+}
+
+var errSnapshotReadOnly = fmt.Errorf("%w: snapshot", ErrReadOnly)
+
+// Test if the snapshot is writable, always false
+func (s *Snapshot) IsWritable() bool {
+	return false
+}
+
+// Get string value for the given key, see Config.GetString
+func (s *Snapshot) GetString(key string) (string, bool) {
+	ret, found, err := s.getExpanded(key)
+	return ret, found && err == nil
+}
+
+// Get raw string value for the given key without expanding its references
+func (s *Snapshot) GetRawString(key string) (string, bool) {
+	value, found := s.values[key]
+	return value, found
+}
+
+// Get the value for the given key with its references expanded if
+// interpolation is enabled, see Config.getExpanded
+func (s *Snapshot) getExpanded(key string) (string, bool, error) {
+	raw, found := s.values[key]
+	if !found || !s.interpolate {
+		return raw, found, nil
+	}
+	ret, err := interpolate(key, raw, s.GetRawString)
+	return ret, true, err
+}
+
+// Snapshot is not writable, always panics
+func (s *Snapshot) SetString(key, value string) {
+	panic("snapshot is not writable")
+}
+
+// Snapshot is not writable, always returns an error wrapping ErrReadOnly
+func (s *Snapshot) TrySetString(key, value string) error {
+	return errSnapshotReadOnly
+}
+
+// Snapshot is not writable, always panics
+func (s *Snapshot) DeleteValue(key string) {
+	panic("snapshot is not writable")
+}
+
+// Snapshot is not writable, always returns an error wrapping ErrReadOnly
+func (s *Snapshot) TryDeleteValue(key string) error {
+	return errSnapshotReadOnly
+}
+
+// List keys, see Viewable for details
+func (s *Snapshot) ListKeys(prefix string, out *KeyList, direct bool) {
+	prefix = normalizePrefix(prefix)
+	for k := range s.values {
+		if strings.HasPrefix(k, prefix) {
+			out.addKey(directKey(k[len(prefix):], direct))
+		}
+	}
+}
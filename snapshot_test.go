@@ -0,0 +1,104 @@
+package config
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigSnapshot(t *testing.T) {
+	defaults := NewLayer("defaults")
+	defaults.SetString("http.port", "8080")
+	defaults.SetString("http.host", "localhost")
+	defaults.SetString("log.level", "info")
+	overrides := NewLayer("overrides")
+	overrides.SetString("http.port", "9090")
+
+	conf := NewConfig()
+	conf.AddLayer(defaults, 0)
+	conf.AddWritableLayer(overrides, 10)
+	conf.DeleteValue("log.level")
+
+	snap := conf.Snapshot()
+	assert.False(t, snap.IsWritable())
+	s, ok := snap.GetString("http.port")
+	assert.True(t, ok)
+	assert.Equal(t, "9090", s)
+	_, ok = snap.GetString("log.level")
+	assert.False(t, ok)
+	assert.ElementsMatch(t, listKeys(snap, "", false), []string{"http.port", "http.host"})
+	assert.ElementsMatch(t, listKeys(snap, "http", true), []string{"port", "host"})
+
+	// later changes are not visible in the snapshot
+	conf.SetString("http.port", "7070")
+	conf.ResetValue("log.level")
+	s, _ = snap.GetString("http.port")
+	assert.Equal(t, "9090", s)
+	_, ok = snap.GetString("log.level")
+	assert.False(t, ok)
+
+	// views work on snapshots
+	port, ok := NewView(snap, "http").GetInt("port")
+	assert.True(t, ok)
+	assert.Equal(t, int64(9090), port)
+
+	// not writable
+	assert.Panics(t, func() {
+		snap.SetString("a", "b")
+	})
+	assert.Panics(t, func() {
+		snap.DeleteValue("http.port")
+	})
+	assert.ErrorIs(t, snap.TrySetString("a", "b"), ErrReadOnly)
+	assert.ErrorIs(t, snap.TryDeleteValue("a"), ErrReadOnly)
+	assert.ErrorIs(t, NewView(snap, "").TrySetString("a", "b"), ErrReadOnly)
+}
+
+func TestConfigSnapshotConsistent(t *testing.T) {
+	high := NewLayer("high")
+	low := NewLayer("low")
+	high.SetString("a", "0")
+	low.SetString("b", "0")
+	conf := NewConfig()
+	conf.AddLayer(high, 10)
+	conf.AddLayer(low, 0)
+
+	// same layers in the other order, snapshots of both must not deadlock
+	reversed := NewConfig()
+	reversed.AddLayer(high, 0)
+	reversed.AddLayer(low, 10)
+
+	// a is always written before b, so no point in time has b greater than a
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 1; i <= 2000; i++ {
+			high.SetString("a", strconv.Itoa(i))
+			low.SetString("b", strconv.Itoa(i))
+		}
+	}()
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				reversed.Snapshot()
+			}
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		snap := NewView(conf.Snapshot(), "")
+		a, _ := snap.GetInt("a")
+		b, _ := snap.GetInt("b")
+		if !assert.LessOrEqual(t, b, a) {
+			return
+		}
+	}
+}
@@ -0,0 +1,464 @@
+package config
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Error returned by Unmarshal when a value cannot be converted to the type of
+// the target field
+type UnmarshalError struct {
+	Key   string       // full key of the value
+	Value string       // raw string value
+	Type  reflect.Type // type of the target field
+	Err   error        // reason of the failure
+}
+
+func (e *UnmarshalError) Error() string {
+	return fmt.Sprintf("config: cannot unmarshal %q at key %q into %s: %v", e.Value, e.Key, e.Type, e.Err)
+}
+
+func (e *UnmarshalError) Unwrap() error {
+	return e.Err
+}
+
+// Error returned by Unmarshal when values of fields tagged as required are
+// missing
+type MissingKeysError struct {
+	Keys []string // full keys of all the missing values
+}
+
+func (e *MissingKeysError) Error() string {
+	return "config: missing required keys: " + strings.Join(e.Keys, ", ")
+}
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	stringerType        = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+)
+
+// Get the config key of a struct field, tag "-" and unexported fields are
+// skipped. Untagged fields use the lower case field name, untagged embedded
+// structs are flattened into the parent
+func fieldKey(f reflect.StructField) (key string, skip bool) {
+	tag, tagged := f.Tag.Lookup("config")
+	switch {
+	case tag == "-":
+		return "", true
+	case f.Anonymous && !tagged && f.Type.Kind() == reflect.Struct:
+		return "", false
+	case !f.IsExported():
+		return "", true
+	case tag != "":
+		return tag, false
+	default:
+		return strings.ToLower(f.Name), false
+	}
+}
+
+// Test if the key has any subkeys
+func hasSubkeys(view View, key string) bool {
+	var keys KeyList
+	view.ListKeys(key, &keys, true)
+	return len(keys.v) > 0
+}
+
+// Get the numeric direct subkeys of the key, sorted by value
+func listIndices(view View, key string) []int {
+	var keys KeyList
+	view.ListKeys(key, &keys, true)
+	ret := []int{}
+	for _, k := range keys.ToSlice() {
+		i, err := strconv.Atoi(k)
+		if err == nil && i >= 0 && strconv.Itoa(i) == k {
+			ret = append(ret, i)
+		}
+	}
+	slices.Sort(ret)
+	return ret
+}
+
+// Convert a raw string value and store it in v
+func unmarshalString(s string, v reflect.Value) error {
+	// types with registered or custom parsing
+	if p, ok := parserOf(v.Type()); ok {
+		ret, err := p.untyped(s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(ret))
+		return nil
+	}
+	if reflect.PointerTo(v.Type()).Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+
+	// basic kinds
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := strconv.ParseUint(s, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return errors.New("unsupported type")
+	}
+	return nil
+}
+
+// Test if values of the type are stored as a single string value
+func isScalarType(t reflect.Type) bool {
+	if _, ok := parserOf(t); ok {
+		return true
+	}
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Slice, reflect.Array, reflect.Map, reflect.Pointer:
+		return false
+	}
+	return true
+}
+
+// State of an Unmarshal call
+type unmarshaler struct {
+	missing []string // missing required keys
+}
+
+// Fill a struct field, apply the default and required tags if no value found
+func (u *unmarshaler) field(view View, f reflect.StructField, v reflect.Value) error {
+	key, skip := fieldKey(f)
+	if skip {
+		return nil
+	}
+	found, err := u.value(view, key, v)
+	if err != nil || found {
+		return err
+	}
+
+	// use default value
+	if def, ok := f.Tag.Lookup("default"); ok {
+		t := v.Type()
+		if t.Kind() == reflect.Pointer && isScalarType(t.Elem()) {
+			if v.IsNil() {
+				v.Set(reflect.New(t.Elem()))
+			}
+			v = v.Elem()
+		} else if !isScalarType(t) {
+			return &UnmarshalError{view.deriveKey(key), def, t, errors.New("default value is supported only for scalar types")}
+		}
+		if err := unmarshalString(def, v); err != nil {
+			return &UnmarshalError{view.deriveKey(key), def, v.Type(), err}
+		}
+		return nil
+	}
+
+	// report missing required value
+	if required, _ := strconv.ParseBool(f.Tag.Get("required")); required {
+		u.missing = append(u.missing, view.deriveKey(key))
+	}
+	return nil
+}
+
+// Fill v from the value or subkeys at key, report if anything was found
+func (u *unmarshaler) value(view View, key string, v reflect.Value) (bool, error) {
+	t := v.Type()
+
+	// scalars
+	if isScalarType(t) {
+		s, ok, err := view.getExpanded(key)
+		if err != nil || !ok {
+			return ok, err
+		}
+		if err := unmarshalString(s, v); err != nil {
+			return true, &UnmarshalError{view.deriveKey(key), s, t, err}
+		}
+		return true, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		sub := view
+		if key != "" {
+			sub = view.SubViewReadOnly(key)
+		}
+		for i := 0; i < t.NumField(); i++ {
+			if err := u.field(sub, t.Field(i), v.Field(i)); err != nil {
+				return true, err
+			}
+		}
+		if key == "" {
+			return true, nil
+		}
+		_, found := view.GetString(key)
+		return found || hasSubkeys(view, key), nil
+
+	case reflect.Pointer:
+		// allocate only if there is anything to store
+		if _, ok := view.GetRawString(key); !ok && !hasSubkeys(view, key) {
+			return false, nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(t.Elem()))
+		}
+		return u.value(view, key, v.Elem())
+
+	case reflect.Slice:
+		indices := listIndices(view, key)
+		if len(indices) == 0 {
+			return u.delimitedSlice(view, key, v)
+		}
+		sub := view.SubViewReadOnly(key)
+		ret := reflect.MakeSlice(t, 0, len(indices))
+		for _, i := range indices {
+			elem := reflect.New(t.Elem()).Elem()
+			ok, err := u.value(sub, strconv.Itoa(i), elem)
+			if err != nil {
+				return true, err
+			}
+			if ok {
+				ret = reflect.Append(ret, elem)
+			}
+		}
+		v.Set(ret)
+		return true, nil
+
+	case reflect.Array:
+		sub := view.SubViewReadOnly(key)
+		found := false
+		for i := 0; i < v.Len(); i++ {
+			ok, err := u.value(sub, strconv.Itoa(i), v.Index(i))
+			if err != nil {
+				return true, err
+			}
+			found = found || ok
+		}
+		return found, nil
+
+	case reflect.Map:
+		var keys KeyList
+		view.ListKeys(key, &keys, true)
+		if len(keys.v) == 0 {
+			return false, nil
+		}
+		if t.Key().Kind() != reflect.String {
+			return true, &UnmarshalError{view.deriveKey(key), "", t, errors.New("map key must be a string")}
+		}
+		sub := view.SubViewReadOnly(key)
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(t))
+		}
+		for _, k := range keys.ToSlice() {
+			elem := reflect.New(t.Elem()).Elem()
+			ok, err := u.value(sub, k, elem)
+			if err != nil {
+				return true, err
+			}
+			if ok {
+				v.SetMapIndex(reflect.ValueOf(k).Convert(t.Key()), elem)
+			}
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// Fill a slice of scalars from a delimited list value
+func (u *unmarshaler) delimitedSlice(view View, key string, v reflect.Value) (bool, error) {
+	t := v.Type()
+	if !isScalarType(t.Elem()) {
+		return false, nil
+	}
+	sv, ok, err := view.getExpanded(key)
+	if err != nil || !ok {
+		return ok, err
+	}
+	items := view.splitList(sv)
+	ret := reflect.MakeSlice(t, len(items), len(items))
+	for i, item := range items {
+		if err := unmarshalString(item, ret.Index(i)); err != nil {
+			return true, &UnmarshalError{view.deriveKey(key), sv, t, err}
+		}
+	}
+	v.Set(ret)
+	return true, nil
+}
+
+// Unmarshal the values of the view into the struct pointed by out. Fields are
+// mapped to keys by the config struct tag, e.g. `config:"server.port"`, tag
+// "-" skips the field. Untagged fields use their lower case name, untagged
+// embedded structs are flattened. Nested structs are filled from subviews,
+// slices from indexed keys (or delimited values, see GetStringList) and maps
+// from the direct subkeys. Values of types with a parser registered by
+// RegisterParser are converted by that parser. Fields without a value are left
+// unchanged, or set from the default tag if present, e.g. `default:"8080"`.
+//
+// If a value cannot be converted, an *UnmarshalError is returned. If fields
+// tagged with `required:"true"` have no value, a *MissingKeysError is returned
+// listing all of them
+func Unmarshal(view View, out any) error {
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return errors.New("config: Unmarshal requires a non-nil pointer to a struct")
+	}
+	var u unmarshaler
+	if _, err := u.value(view, "", v.Elem()); err != nil {
+		return err
+	}
+	if len(u.missing) > 0 {
+		return &MissingKeysError{u.missing}
+	}
+	return nil
+}
+
+// Convert v to its raw string form
+func marshalString(v reflect.Value) (string, error) {
+	// types with registered or custom formatting
+	t := v.Type()
+	if f, ok := formatterOf(t); ok {
+		return f(v.Interface()), nil
+	}
+	if t == durationType {
+		return time.Duration(v.Int()).String(), nil
+	}
+	if t.Implements(textMarshalerType) {
+		b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(b), err
+	}
+	if v.CanAddr() && reflect.PointerTo(t).Implements(textMarshalerType) {
+		b, err := v.Addr().Interface().(encoding.TextMarshaler).MarshalText()
+		return string(b), err
+	}
+	if t.Kind() == reflect.Struct && t.Implements(stringerType) {
+		return v.Interface().(fmt.Stringer).String(), nil
+	}
+	if t.Kind() == reflect.Struct && v.CanAddr() && reflect.PointerTo(t).Implements(stringerType) {
+		return v.Addr().Interface().(fmt.Stringer).String(), nil
+	}
+
+	// basic kinds
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, t.Bits()), nil
+	default:
+		return "", errors.New("unsupported type")
+	}
+}
+
+// Write v to the value or subkeys at key
+func marshalValue(view View, key string, v reflect.Value) error {
+	t := v.Type()
+
+	// scalars
+	if isScalarType(t) || t.Implements(textMarshalerType) {
+		s, err := marshalString(v)
+		if err != nil {
+			return fmt.Errorf("config: cannot marshal %s at key %q: %w", t, view.deriveKey(key), err)
+		}
+		if err := view.TrySetString(key, s); err != nil {
+			return fmt.Errorf("config: cannot marshal %s at key %q: %w", t, view.deriveKey(key), err)
+		}
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		sub := view
+		if key != "" {
+			sub = view.SubView(key)
+		}
+		for i := 0; i < t.NumField(); i++ {
+			fkey, skip := fieldKey(t.Field(i))
+			if skip {
+				continue
+			}
+			if err := marshalValue(sub, fkey, v.Field(i)); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Pointer:
+		if !v.IsNil() {
+			return marshalValue(view, key, v.Elem())
+		}
+
+	case reflect.Slice, reflect.Array:
+		sub := view.SubView(key)
+		for i := 0; i < v.Len(); i++ {
+			if err := marshalValue(sub, strconv.Itoa(i), v.Index(i)); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return fmt.Errorf("config: cannot marshal %s at key %q: map key must be a string", t, view.deriveKey(key))
+		}
+		sub := view.SubView(key)
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := marshalValue(sub, iter.Key().String(), iter.Value()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Marshal the struct (or pointer to struct) in into the view, which must be
+// writable, otherwise an error wrapping ErrReadOnly is returned. Fields are
+// mapped to keys the same way as in Unmarshal. Nested structs are written to
+// subviews, slices and arrays to indexed keys and maps to subkeys, nil
+// pointers are skipped. Values of types with a formatter registered by
+// RegisterFormatter are converted by that formatter
+func Marshal(in any, view View) error {
+	if !view.IsWritable() {
+		return fmt.Errorf("config: cannot marshal: %w", ErrReadOnly)
+	}
+	v := reflect.ValueOf(in)
+	if v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return errors.New("config: Marshal requires a struct or a non-nil pointer to a struct")
+	}
+	return marshalValue(view, "", v)
+}
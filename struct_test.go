@@ -0,0 +1,321 @@
+package config
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testServerSettings struct {
+	Port             int           `config:"port"`
+	Name             string        `config:"servername"`
+	MultiConnections bool          `config:"multiconnections"`
+	Timeout          time.Duration `config:"timeout"`
+	Ratio            float32       `config:"ratio"`
+	MaxConns         uint16        `config:"limits.maxconns"`
+	Address          net.IP        `config:"address"`
+}
+
+type testBase struct {
+	Version string
+}
+
+type testSettings struct {
+	testBase
+	Server  testServerSettings `config:"http.server"`
+	Hosts   []string           `config:"http.hosts"`
+	Backend []struct {
+		Host string
+		Port int
+	} `config:"backends"`
+	Labels  map[string]string `config:"labels"`
+	Limits  map[string]int    `config:"limits"`
+	Pair    [2]int            `config:"pair"`
+	Opt     *testServerSettings
+	NoValue *int
+	Ignored string `config:"-"`
+	hidden  string
+}
+
+func TestUnmarshal(t *testing.T) {
+	l := NewLayer("test")
+	l.SetString("version", "1.2")
+	l.SetString("http.server.port", "8080")
+	l.SetString("http.server.servername", "MyTestServer")
+	l.SetString("http.server.multiconnections", "true")
+	l.SetString("http.server.timeout", "1m30s")
+	l.SetString("http.server.ratio", "0.5")
+	l.SetString("http.server.limits.maxconns", "0x100")
+	l.SetString("http.server.address", "10.0.0.1")
+	l.SetString("http.hosts.0", "a")
+	l.SetString("http.hosts.10", "c")
+	l.SetString("http.hosts.2", "b")
+	l.SetString("http.hosts.x", "ignored")
+	l.SetString("backends.0.host", "b1")
+	l.SetString("backends.0.port", "81")
+	l.SetString("backends.1.host", "b2")
+	l.SetString("labels.env", "prod")
+	l.SetString("labels.team", "core")
+	l.SetString("limits.a", "1")
+	l.SetString("limits.b.nested", "2")
+	l.SetString("pair.1", "5")
+	l.SetString("opt.port", "9")
+	l.SetString("ignored", "x")
+	l.SetString("hidden", "x")
+
+	out := testSettings{Ignored: "keep"}
+	err := Unmarshal(NewView(l, ""), &out)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "1.2", out.Version)
+	assert.Equal(t, testServerSettings{
+		Port:             8080,
+		Name:             "MyTestServer",
+		MultiConnections: true,
+		Timeout:          90 * time.Second,
+		Ratio:            0.5,
+		MaxConns:         256,
+		Address:          net.IPv4(10, 0, 0, 1),
+	}, out.Server)
+	assert.Equal(t, []string{"a", "b", "c"}, out.Hosts)
+	assert.Equal(t, 2, len(out.Backend))
+	assert.Equal(t, "b1", out.Backend[0].Host)
+	assert.Equal(t, 81, out.Backend[0].Port)
+	assert.Equal(t, "b2", out.Backend[1].Host)
+	assert.Equal(t, 0, out.Backend[1].Port)
+	assert.Equal(t, map[string]string{"env": "prod", "team": "core"}, out.Labels)
+	assert.Equal(t, map[string]int{"a": 1}, out.Limits)
+	assert.Equal(t, [2]int{0, 5}, out.Pair)
+	assert.NotNil(t, out.Opt)
+	assert.Equal(t, 9, out.Opt.Port)
+	assert.Nil(t, out.NoValue)
+	assert.Equal(t, "keep", out.Ignored)
+	assert.Equal(t, "", out.hidden)
+
+	// unmarshal a subview
+	var server testServerSettings
+	err = Unmarshal(NewView(l, "http.server"), &server)
+	assert.Nil(t, err)
+	assert.Equal(t, out.Server, server)
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	l := NewLayer("test")
+	l.SetString("http.server.port", "80a")
+	view := NewView(l, "")
+
+	// conversion error names key and type
+	var out testSettings
+	err := Unmarshal(view, &out)
+	var uerr *UnmarshalError
+	assert.True(t, errors.As(err, &uerr))
+	assert.Equal(t, "http.server.port", uerr.Key)
+	assert.Equal(t, "80a", uerr.Value)
+	assert.Equal(t, "int", uerr.Type.String())
+	assert.True(t, errors.Is(err, strconv.ErrSyntax))
+	assert.EqualError(t, err, `config: cannot unmarshal "80a" at key "http.server.port" into int: strconv.ParseInt: parsing "80a": invalid syntax`)
+
+	// key relative to a subview is reported in full
+	var server testServerSettings
+	err = Unmarshal(NewView(l, "http.server"), &server)
+	assert.True(t, errors.As(err, &uerr))
+	assert.Equal(t, "http.server.port", uerr.Key)
+
+	// out of range
+	l.SetString("http.server.port", "1")
+	l.SetString("http.server.limits.maxconns", "70000")
+	err = Unmarshal(view, &out)
+	assert.True(t, errors.As(err, &uerr))
+	assert.Equal(t, "http.server.limits.maxconns", uerr.Key)
+	assert.Equal(t, "uint16", uerr.Type.String())
+
+	// unsupported types
+	var bad struct {
+		C chan int
+		M map[int]string
+	}
+	l.SetString("c", "x")
+	err = Unmarshal(view, &bad)
+	assert.EqualError(t, err, `config: cannot unmarshal "x" at key "c" into chan int: unsupported type`)
+	l.DeleteValue("c")
+	l.SetString("m.1", "x")
+	err = Unmarshal(view, &bad)
+	assert.EqualError(t, err, `config: cannot unmarshal "" at key "m" into map[int]string: map key must be a string`)
+
+	// invalid targets
+	assert.NotNil(t, Unmarshal(view, out))
+	assert.NotNil(t, Unmarshal(view, (*testSettings)(nil)))
+	assert.NotNil(t, Unmarshal(view, new(int)))
+}
+
+func TestMarshal(t *testing.T) {
+	in := testSettings{
+		testBase: testBase{Version: "1.2"},
+		Server: testServerSettings{
+			Port:             8080,
+			Name:             "MyTestServer",
+			MultiConnections: true,
+			Timeout:          90 * time.Second,
+			Ratio:            0.5,
+			MaxConns:         256,
+			Address:          net.IPv4(10, 0, 0, 1),
+		},
+		Hosts:   []string{"a", "b"},
+		Labels:  map[string]string{"env": "prod"},
+		Pair:    [2]int{4, 5},
+		Ignored: "x",
+	}
+	in.Backend = append(in.Backend, struct {
+		Host string
+		Port int
+	}{"b1", 81})
+
+	l := NewLayer("defaults")
+	err := Marshal(&in, NewView(l, "app"))
+	assert.Nil(t, err)
+
+	keys := listKeys(l, "app", false)
+	assert.ElementsMatch(t, keys, []string{
+		"version",
+		"http.server.port",
+		"http.server.servername",
+		"http.server.multiconnections",
+		"http.server.timeout",
+		"http.server.ratio",
+		"http.server.limits.maxconns",
+		"http.server.address",
+		"http.hosts.0",
+		"http.hosts.1",
+		"backends.0.host",
+		"backends.0.port",
+		"labels.env",
+		"pair.0",
+		"pair.1",
+	})
+
+	view := NewView(l, "app")
+	s, ok := view.GetString("http.server.timeout")
+	assert.True(t, ok)
+	assert.Equal(t, "1m30s", s)
+
+	s, ok = view.GetString("http.server.address")
+	assert.True(t, ok)
+	assert.Equal(t, "10.0.0.1", s)
+
+	i, ok := view.GetInt("http.server.port")
+	assert.True(t, ok)
+	assert.EqualValues(t, 8080, i)
+
+	b, ok := view.GetBool("http.server.multiconnections")
+	assert.True(t, ok)
+	assert.True(t, b)
+
+	// read back the same struct
+	var out testSettings
+	err = Unmarshal(view, &out)
+	assert.Nil(t, err)
+	in.Ignored = ""
+	assert.Equal(t, in, out)
+}
+
+func TestMarshalErrors(t *testing.T) {
+	l := NewLayer("test")
+	view := NewView(l, "")
+
+	err := Marshal(struct{ C chan int }{make(chan int)}, view)
+	assert.EqualError(t, err, `config: cannot marshal chan int at key "c": unsupported type`)
+
+	err = Marshal(struct{ M map[int]string }{map[int]string{1: "x"}}, view)
+	assert.EqualError(t, err, `config: cannot marshal map[int]string at key "m": map key must be a string`)
+
+	assert.NotNil(t, Marshal(5, view))
+	assert.NotNil(t, Marshal((*testSettings)(nil), view))
+
+	// read-only target
+	l.LockReadOnly()
+	err = Marshal(testServerSettings{}, view)
+	assert.True(t, errors.Is(err, ErrReadOnly))
+
+	// layer locked after the config was set up
+	l2 := NewLayer("test2")
+	conf := NewConfig()
+	conf.AddWritableLayer(l2, 0)
+	l2.LockReadOnly()
+	err = Marshal(testServerSettings{}, NewView(conf, ""))
+	assert.True(t, errors.Is(err, ErrReadOnly))
+}
+
+func TestUnmarshalDefaultRequired(t *testing.T) {
+	type limits struct {
+		MaxConns int `config:"maxconns" required:"true"`
+		Burst    int `config:"burst" default:"10"`
+	}
+	type settings struct {
+		Port    int           `config:"port" default:"8080"`
+		Name    string        `config:"name" required:"true"`
+		Timeout time.Duration `config:"timeout" default:"30s"`
+		Ratio   *float64      `config:"ratio" default:"0.5"`
+		Host    string        `config:"host" required:"true"`
+		Tags    []string      `config:"tags" required:"true"`
+		Limits  limits        `config:"limits"`
+		Opt     *limits       `config:"opt"`
+		Debug   bool          `config:"debug" required:"false"`
+	}
+
+	// all missing
+	l := NewLayer("test")
+	l.SetString("app.host", "localhost")
+	view := NewView(l, "app")
+	var out settings
+	err := Unmarshal(view, &out)
+	var merr *MissingKeysError
+	assert.True(t, errors.As(err, &merr))
+	assert.Equal(t, []string{"app.name", "app.tags", "app.limits.maxconns"}, merr.Keys)
+	assert.EqualError(t, err, "config: missing required keys: app.name, app.tags, app.limits.maxconns")
+
+	// defaults are applied anyway
+	assert.Equal(t, 8080, out.Port)
+	assert.Equal(t, 30*time.Second, out.Timeout)
+	assert.NotNil(t, out.Ratio)
+	assert.Equal(t, 0.5, *out.Ratio)
+	assert.Equal(t, 10, out.Limits.Burst)
+	assert.Equal(t, "localhost", out.Host)
+	assert.Nil(t, out.Opt)
+
+	// provide the required values, override a default
+	l.SetString("app.name", "test")
+	l.SetString("app.tags.0", "x")
+	l.SetString("app.limits.maxconns", "100")
+	l.SetString("app.port", "9090")
+	out = settings{}
+	err = Unmarshal(view, &out)
+	assert.Nil(t, err)
+	assert.Equal(t, 9090, out.Port)
+	assert.Equal(t, 100, out.Limits.MaxConns)
+	assert.Equal(t, []string{"x"}, out.Tags)
+
+	// required values of present optional structs are checked
+	l.SetString("app.opt.burst", "1")
+	err = Unmarshal(view, &out)
+	assert.EqualError(t, err, "config: missing required keys: app.opt.maxconns")
+
+	// invalid default
+	var bad struct {
+		Retries int `config:"retries" default:"x"`
+	}
+	err = Unmarshal(view, &bad)
+	var uerr *UnmarshalError
+	assert.True(t, errors.As(err, &uerr))
+	assert.Equal(t, "app.retries", uerr.Key)
+	assert.Equal(t, "x", uerr.Value)
+
+	var badslice struct {
+		List []int `config:"list" default:"1"`
+	}
+	err = Unmarshal(view, &badslice)
+	assert.EqualError(t, err, `config: cannot unmarshal "1" at key "app.list" into []int: default value is supported only for scalar types`)
+}
@@ -0,0 +1,275 @@
+package config
+
+import (
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Change of a value, delivered to the callbacks registered with Layer.Subscribe
+// and Config.Subscribe
+type ChangeEvent struct {
+	Key      string // changed key
+	OldValue string // previous value, empty if OldFound is false
+	NewValue string // current value, empty if NewFound is false
+	OldFound bool   // the key had a value before the change
+	NewFound bool   // the key has a value after the change
+	Layer    *Layer // layer that was modified, added or removed
+}
+
+// Handle of a change subscription
+type Subscription struct {
+	once   sync.Once
+	cancel func()
+}
+
+// Stop receiving change events. Events of a write in progress may still be
+// delivered. Calling it more than once is a no-op
+func (s *Subscription) Unsubscribe() {
+	s.once.Do(s.cancel)
+}
+
+type subscriber struct {
+	prefix string
+	fn     func(ChangeEvent)
+}
+
+// Test if the key is the prefix itself or is under the prefix
+func (s *subscriber) matches(key string) bool {
+	return s.prefix == "" || key == s.prefix || strings.HasPrefix(key, s.prefix+".")
+}
+
+// Deliver events to the matching subscribers
+func dispatchEvents(events []ChangeEvent, subs []*subscriber) {
+	for _, ev := range events {
+		for _, sub := range subs {
+			if sub.matches(ev.Key) {
+				sub.fn(ev)
+			}
+		}
+	}
+}
+
+// Add a subscriber to a copy of the list, so that lists taken for dispatching
+// are never modified
+func addSubscriber[T comparable](list []T, sub T) []T {
+	return append(slices.Clip(list), sub)
+}
+
+// Remove a subscriber from a copy of the list
+func removeSubscriber[T comparable](list []T, sub T) []T {
+	return slices.DeleteFunc(slices.Clone(list), func(s T) bool {
+		return s == sub
+	})
+}
+
+// State of a key in a layer
+type keyState struct {
+	value   string
+	found   bool
+	deleted bool // has a tombstone
+}
+
+// Change of a key in a layer, including tombstone changes
+type layerChange struct {
+	key      string
+	old, new keyState
+}
+
+// Observer of all changes of a layer, used by the configs containing it
+type layerObserver struct {
+	fn func(layer *Layer, changes []layerChange)
+}
+
+// Call fn on every change of the values under the given key prefix. An empty
+// prefix matches all keys, otherwise the prefix itself and its subkeys match,
+// e.g. "log" matches "log" and "log.level", but not "logger". The callback
+// runs synchronously in the goroutine doing the write, after the layer is
+// unlocked, so it may read the layer. To receive events through a channel,
+// send to the channel in the callback
+func (l *Layer) Subscribe(prefix string, fn func(ChangeEvent)) *Subscription {
+	sub := &subscriber{strings.TrimSuffix(prefix, "."), fn}
+
+	// lock mutex
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	l.subs = addSubscriber(l.subs, sub)
+	return &Subscription{cancel: func() {
+		l.mx.Lock()
+		defer l.mx.Unlock()
+		l.subs = removeSubscriber(l.subs, sub)
+	}}
+}
+
+// Register an observer receiving all changes of the layer
+func (l *Layer) observe(fn func(layer *Layer, changes []layerChange)) *layerObserver {
+	o := &layerObserver{fn}
+
+	// lock mutex
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	l.observers = addSubscriber(l.observers, o)
+	return o
+}
+
+// Remove an observer registered by observe
+func (l *Layer) unobserve(o *layerObserver) {
+	// lock mutex
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	l.observers = removeSubscriber(l.observers, o)
+}
+
+// Test if anyone listens to the changes of the layer. Must be called with the
+// mutex locked
+func (l *Layer) hasListeners() bool {
+	return len(l.subs) > 0 || len(l.observers) > 0
+}
+
+// Get the state of the key. Must be called with the mutex locked
+func (l *Layer) stateOf(key string) keyState {
+	value, found := l.values[key]
+	return keyState{value, found, l.tombstones[key]}
+}
+
+// Modify the writable layer with the mutex locked, then notify the listeners
+// with the changes reported by fn after unlocking
+func (l *Layer) update(fn func() []layerChange) error {
+	return l.modify(true, fn)
+}
+
+// Modify the layer like update, the writable flag is checked only if
+// checkWritable is true
+func (l *Layer) modify(checkWritable bool, fn func() []layerChange) error {
+	l.mx.Lock()
+	if checkWritable && !l.writable {
+		l.mx.Unlock()
+		return l.errReadOnly()
+	}
+	changes := fn()
+	subs, observers := l.subs, l.observers
+	l.mx.Unlock()
+
+	if len(changes) == 0 {
+		return nil
+	}
+	for _, o := range observers {
+		o.fn(l, changes)
+	}
+	if len(subs) > 0 {
+		events := []ChangeEvent{}
+		for _, ch := range changes {
+			if ch.old.found != ch.new.found || ch.old.value != ch.new.value {
+				events = append(events, ChangeEvent{
+					ch.key, ch.old.value, ch.new.value, ch.old.found, ch.new.found, l,
+				})
+			}
+		}
+		dispatchEvents(events, subs)
+	}
+	return nil
+}
+
+// Call fn on every change of the effective values under the given key prefix,
+// see Layer.Subscribe for the prefix matching. Only real changes are reported,
+// e.g. setting a value in a layer is not reported if a higher priority layer
+// overrides it. Adding and removing layers are also reported. The Layer field
+// of the events is the layer whose change caused the event
+func (c *Config) Subscribe(prefix string, fn func(ChangeEvent)) *Subscription {
+	sub := &subscriber{strings.TrimSuffix(prefix, "."), fn}
+
+	// lock layer list
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	c.subs = addSubscriber(c.subs, sub)
+	c.updateObservers()
+	return &Subscription{cancel: func() {
+		c.mx.Lock()
+		defer c.mx.Unlock()
+		c.subs = removeSubscriber(c.subs, sub)
+		c.updateObservers()
+	}}
+}
+
+// Get the effective value of the key, using the given state for the layer
+// instead of its current one. Layer may be nil
+func (c *Config) effectiveValue(key string, layer *Layer, state keyState) keyState {
+	for _, item := range c.loadItems() {
+		var st keyState
+		if item.layer == layer {
+			st = state
+		} else {
+			st.value, st.found, st.deleted = item.layer.lookup(key)
+		}
+		if st.found {
+			return keyState{st.value, true, false}
+		}
+		if st.deleted {
+			break
+		}
+	}
+	return keyState{}
+}
+
+// Append an event to the list if the effective value changed
+func appendEffectiveChange(events []ChangeEvent, key string, old, new keyState, layer *Layer) []ChangeEvent {
+	if old.found != new.found || old.value != new.value {
+		events = append(events, ChangeEvent{key, old.value, new.value, old.found, new.found, layer})
+	}
+	return events
+}
+
+// Observer function of the layers of the config
+func (c *Config) layerChanged(layer *Layer, changes []layerChange) {
+	c.invalidateCache()
+
+	// lock layer list
+	c.mx.Lock()
+	subs := c.subs
+	events := []ChangeEvent{}
+	if len(subs) > 0 {
+		for _, ch := range changes {
+			old := c.effectiveValue(ch.key, layer, ch.old)
+			new := c.effectiveValue(ch.key, layer, ch.new)
+			events = appendEffectiveChange(events, ch.key, old, new, layer)
+		}
+	}
+	c.mx.Unlock()
+
+	dispatchEvents(events, subs)
+}
+
+// Get the effective values of the keys defined or deleted in the layer, nil if
+// the config has no subscribers. Must be called with the layer list locked
+func (c *Config) effectiveValuesOf(layer *Layer) map[string]keyState {
+	if len(c.subs) == 0 {
+		return nil
+	}
+	keys := map[string]bool{}
+	layer.collectTombstones(keys)
+	var list KeyList
+	layer.ListKeys("", &list, false)
+	for k := range list.v {
+		keys[k] = true
+	}
+
+	ret := make(map[string]keyState, len(keys))
+	for k := range keys {
+		ret[k] = c.effectiveValue(k, nil, keyState{})
+	}
+	return ret
+}
+
+// Compare the effective values before adding or removing the layer with the
+// current ones. Must be called with the layer list locked
+func (c *Config) effectiveChanges(layer *Layer, before map[string]keyState) []ChangeEvent {
+	events := []ChangeEvent{}
+	for k, old := range before {
+		events = appendEffectiveChange(events, k, old, c.effectiveValue(k, nil, keyState{}), layer)
+	}
+	return events
+}
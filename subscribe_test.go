@@ -0,0 +1,171 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func collectEvents(events *[]ChangeEvent) func(ChangeEvent) {
+	return func(ev ChangeEvent) {
+		*events = append(*events, ev)
+	}
+}
+
+func TestLayerSubscribe(t *testing.T) {
+	l := NewLayer("test")
+	var all, log []ChangeEvent
+	suball := l.Subscribe("", collectEvents(&all))
+	sublog := l.Subscribe("log.", collectEvents(&log))
+
+	l.SetString("log.level", "info")
+	l.SetString("logger", "x")
+	l.SetString("log", "y")
+	l.SetString("log.level", "info") // no change
+	l.DeleteValue("log.level")
+	l.DeleteValue("noexist")
+	assert.Equal(t, []ChangeEvent{
+		{"log.level", "", "info", false, true, l},
+		{"log", "", "y", false, true, l},
+		{"log.level", "info", "", true, false, l},
+	}, log)
+	assert.Equal(t, 4, len(all))
+
+	// clear
+	all = nil
+	l.Clear()
+	assert.ElementsMatch(t, []ChangeEvent{
+		{"logger", "x", "", true, false, l},
+		{"log", "y", "", true, false, l},
+	}, all)
+
+	// the callback may read the layer
+	l.Subscribe("key", func(ev ChangeEvent) {
+		s, _ := ev.Layer.GetString(ev.Key)
+		assert.Equal(t, ev.NewValue, s)
+	})
+	l.SetString("key", "value")
+
+	// unsubscribe
+	suball.Unsubscribe()
+	sublog.Unsubscribe()
+	sublog.Unsubscribe()
+	all, log = nil, nil
+	l.SetString("log.level", "debug")
+	assert.Nil(t, all)
+	assert.Nil(t, log)
+
+	// read-only layer does not notify
+	l.LockReadOnly()
+	l.Subscribe("", collectEvents(&all))
+	assert.NotNil(t, l.TrySetString("a", "b"))
+	assert.Nil(t, all)
+}
+
+func TestConfigSubscribe(t *testing.T) {
+	defaults := NewLayer("defaults")
+	defaults.SetString("log.level", "info")
+	defaults.SetString("rate", "100/s")
+	overrides := NewLayer("overrides")
+
+	conf := NewConfig()
+	var events []ChangeEvent
+	sub := conf.Subscribe("log", collectEvents(&events))
+
+	// adding a layer changes the effective values
+	conf.AddLayer(defaults, 0)
+	assert.Equal(t, []ChangeEvent{{"log.level", "", "info", false, true, defaults}}, events)
+
+	// override in a higher priority layer
+	events = nil
+	conf.AddWritableLayer(overrides, 10)
+	assert.Nil(t, events)
+	conf.SetString("log.level", "debug")
+	assert.Equal(t, []ChangeEvent{{"log.level", "info", "debug", true, true, overrides}}, events)
+
+	// changing an overridden value is not reported
+	events = nil
+	defaults.SetString("log.level", "warn")
+	assert.Nil(t, events)
+
+	// setting the same value is not reported
+	overrides.SetString("log.level", "debug")
+	assert.Nil(t, events)
+
+	// reset the override, lower layer supplies the value again
+	conf.ResetValue("log.level")
+	assert.Equal(t, []ChangeEvent{{"log.level", "debug", "warn", true, true, overrides}}, events)
+
+	// tombstone hides the value
+	events = nil
+	conf.DeleteValue("log.level")
+	assert.Equal(t, []ChangeEvent{{"log.level", "warn", "", true, false, overrides}}, events)
+	events = nil
+	defaults.SetString("log.level", "error")
+	assert.Nil(t, events)
+
+	// clearing the layer removes the tombstone
+	overrides.Clear()
+	assert.Equal(t, []ChangeEvent{{"log.level", "", "error", false, true, overrides}}, events)
+
+	// removing a layer
+	events = nil
+	overrides.SetString("log.level", "debug")
+	events = nil
+	assert.True(t, conf.RemoveLayer(overrides))
+	assert.Equal(t, []ChangeEvent{{"log.level", "debug", "error", true, true, overrides}}, events)
+
+	// removed layer is not observed anymore
+	events = nil
+	overrides.SetString("log.level", "trace")
+	assert.Nil(t, events)
+
+	// the callback may read the config
+	conf.Subscribe("rate", func(ev ChangeEvent) {
+		s, _ := conf.GetString(ev.Key)
+		assert.Equal(t, ev.NewValue, s)
+	})
+	defaults.SetString("rate", "5/s")
+
+	// unsubscribe
+	sub.Unsubscribe()
+	defaults.SetString("log.level", "info")
+	assert.Nil(t, events)
+}
+
+func TestConfigObservers(t *testing.T) {
+	shared := NewLayer("shared")
+	observers := func() int {
+		shared.mx.RLock()
+		defer shared.mx.RUnlock()
+		return len(shared.observers)
+	}
+
+	// configs without subscribers or caching do not observe their layers
+	for i := 0; i < 10; i++ {
+		NewConfig().AddLayer(shared, 0)
+	}
+	assert.Equal(t, 0, observers())
+
+	// observed while subscribed
+	conf := NewConfig()
+	conf.AddLayer(shared, 0)
+	sub1 := conf.Subscribe("", func(ChangeEvent) {})
+	sub2 := conf.Subscribe("", func(ChangeEvent) {})
+	assert.Equal(t, 1, observers())
+	sub1.Unsubscribe()
+	assert.Equal(t, 1, observers())
+	sub2.Unsubscribe()
+	assert.Equal(t, 0, observers())
+
+	// observed while caching
+	conf.SetCaching(true)
+	assert.Equal(t, 1, observers())
+	conf.SetCaching(false)
+	assert.Equal(t, 0, observers())
+
+	// removed layers are not observed
+	conf.SetCaching(true)
+	conf.RemoveLayer(shared)
+	assert.Equal(t, 0, observers())
+}
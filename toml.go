@@ -0,0 +1,740 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	tomlDateTime = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}[Tt ]\d{2}:\d{2}:\d{2}(\.\d+)?([Zz]|[+-]\d{2}:\d{2})?$`)
+	tomlDate     = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	tomlTime     = regexp.MustCompile(`^\d{2}:\d{2}:\d{2}(\.\d+)?$`)
+	tomlDec      = regexp.MustCompile(`^[+-]?(0|[1-9](_?\d)*)$`)
+	tomlHex      = regexp.MustCompile(`^0x[0-9A-Fa-f](_?[0-9A-Fa-f])*$`)
+	tomlOct      = regexp.MustCompile(`^0o[0-7](_?[0-7])*$`)
+	tomlBin      = regexp.MustCompile(`^0b[01](_?[01])*$`)
+	tomlFloat    = regexp.MustCompile(`^[+-]?(0|[1-9](_?\d)*)(\.\d(_?\d)*)?([eE][+-]?\d(_?\d)*)?$`)
+	tomlSpecial  = regexp.MustCompile(`^[+-]?(inf|nan)$`)
+	tomlBareKey  = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+)
+
+type tomlParser struct {
+	s        string
+	pos      int
+	line     int
+	viewable Viewable
+	table    string          // key prefix of the current table
+	arrays   map[string]int  // element count of the arrays of tables
+	tables   map[string]bool // explicitly defined tables
+	dotted   map[string]bool // tables defined by dotted keys
+	keys     map[string]bool // already defined keys, including arrays and inline tables
+	parents  map[string]bool // prefixes of the defined keys and tables
+}
+
+func (p *tomlParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("toml: line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+func (p *tomlParser) peek() byte {
+	if p.pos < len(p.s) {
+		return p.s[p.pos]
+	}
+	return 0
+}
+
+func (p *tomlParser) eof() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *tomlParser) skipWs() {
+	for !p.eof() && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *tomlParser) skipComment() {
+	if p.peek() == '#' {
+		for !p.eof() && p.s[p.pos] != '\n' && p.s[p.pos] != '\r' {
+			p.pos++
+		}
+	}
+}
+
+// Consume a line ending, return false if there is none at the current position
+func (p *tomlParser) newline() bool {
+	if strings.HasPrefix(p.s[p.pos:], "\r\n") {
+		p.pos += 2
+	} else if p.peek() == '\n' {
+		p.pos++
+	} else {
+		return false
+	}
+	p.line++
+	return true
+}
+
+// Skip whitespaces, comments and line endings
+func (p *tomlParser) skipWsNl() {
+	for {
+		p.skipWs()
+		p.skipComment()
+		if !p.newline() {
+			return
+		}
+	}
+}
+
+// Expect end of line, optionally preceded by whitespaces and a comment
+func (p *tomlParser) expectEol() error {
+	p.skipWs()
+	p.skipComment()
+	if p.eof() || p.newline() {
+		return nil
+	}
+	return p.errorf("unexpected character %q", p.peek())
+}
+
+func (p *tomlParser) expect(c byte) error {
+	if p.peek() != c {
+		if p.eof() {
+			return p.errorf("expected %q, found end of file", c)
+		}
+		return p.errorf("expected %q, found %q", c, p.peek())
+	}
+	p.pos++
+	return nil
+}
+
+func (p *tomlParser) parse() error {
+	for {
+		p.skipWs()
+		switch c := p.peek(); {
+		case p.eof():
+			return nil
+		case c == '#':
+			p.skipComment()
+		case c == '\n' || c == '\r':
+			if !p.newline() {
+				return p.errorf("unexpected character %q", c)
+			}
+		case c == '[':
+			if err := p.parseHeader(); err != nil {
+				return err
+			}
+		default:
+			if err := p.parseKeyValue(p.table); err != nil {
+				return err
+			}
+			if err := p.expectEol(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (p *tomlParser) parseHeader() error {
+	// check header kind
+	p.pos++
+	isArray := p.peek() == '['
+	if isArray {
+		p.pos++
+	}
+
+	// parse table name
+	p.skipWs()
+	parts, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	p.skipWs()
+	if err := p.expect(']'); err != nil {
+		return err
+	}
+	if isArray {
+		if err := p.expect(']'); err != nil {
+			return err
+		}
+	}
+
+	// resolve the table name, arrays of tables refer to their last element
+	prefix := ""
+	for i, part := range parts {
+		prefix = joinKey(prefix, part)
+		last := i == len(parts)-1
+		if p.keys[prefix] {
+			return p.errorf("key %q is already defined", prefix)
+		}
+		if last && p.dotted[prefix] {
+			return p.errorf("table %q is already defined", prefix)
+		}
+		n, ok := p.arrays[prefix]
+		if last && isArray {
+			if p.tables[prefix] {
+				return p.errorf("table %q is already defined", prefix)
+			}
+			p.arrays[prefix] = n + 1
+			prefix = joinKey(prefix, strconv.Itoa(n))
+		} else if ok {
+			if last {
+				return p.errorf("array of tables %q cannot be redefined as a table", prefix)
+			}
+			prefix = joinKey(prefix, strconv.Itoa(n-1))
+		}
+	}
+	if p.keys[prefix] {
+		return p.errorf("key %q is already defined", prefix)
+	}
+	if !isArray {
+		if p.tables[prefix] {
+			return p.errorf("table %q is already defined", prefix)
+		}
+		p.tables[prefix] = true
+	}
+	p.addParents(prefix)
+	p.table = prefix
+	return p.expectEol()
+}
+
+// Parse a dotted key, return its parts
+func (p *tomlParser) parseKey() ([]string, error) {
+	var parts []string
+	for {
+		p.skipWs()
+		var part string
+		switch p.peek() {
+		case '"', '\'':
+			s, err := p.parseString()
+			if err != nil {
+				return nil, err
+			}
+			part = s
+		default:
+			st := p.pos
+			for !p.eof() && (isTomlBareKeyChar(p.s[p.pos])) {
+				p.pos++
+			}
+			if st == p.pos {
+				if p.eof() {
+					return nil, p.errorf("expected key, found end of file")
+				}
+				return nil, p.errorf("invalid character %q in key", p.peek())
+			}
+			part = p.s[st:p.pos]
+		}
+		parts = append(parts, part)
+
+		// continue if dotted
+		p.skipWs()
+		if p.peek() != '.' {
+			return parts, nil
+		}
+		p.pos++
+	}
+}
+
+func isTomlBareKeyChar(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_' || c == '-'
+}
+
+func (p *tomlParser) parseKeyValue(prefix string) error {
+	parts, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	p.skipWs()
+	if err := p.expect('='); err != nil {
+		return err
+	}
+	p.skipWs()
+	key := prefix
+	for i, part := range parts {
+		key = joinKey(key, part)
+		if i == len(parts)-1 {
+			break
+		}
+
+		// the parts before the last one define tables, which cannot extend
+		// values or tables defined by headers
+		if p.keys[key] {
+			return p.errorf("key %q is already defined", key)
+		}
+		if _, ok := p.arrays[key]; ok || p.tables[key] {
+			return p.errorf("table %q is already defined", key)
+		}
+		p.dotted[key] = true
+	}
+	return p.parseValue(key)
+}
+
+// Mark the key as defined, fail if it is already defined or has subkeys
+func (p *tomlParser) defineKey(key string) error {
+	if p.keys[key] || p.tables[key] || p.parents[key] {
+		return p.errorf("key %q is already defined", key)
+	}
+	p.keys[key] = true
+	p.addParents(key)
+	return nil
+}
+
+// Remember the prefixes of the key, so that they cannot be defined as values
+func (p *tomlParser) addParents(key string) {
+	for dot := strings.LastIndexByte(key, '.'); dot >= 0; dot = strings.LastIndexByte(key, '.') {
+		key = key[:dot]
+		p.parents[key] = true
+	}
+}
+
+func (p *tomlParser) setValue(key, value string) error {
+	if err := p.defineKey(key); err != nil {
+		return err
+	}
+	p.viewable.SetString(key, value)
+	return nil
+}
+
+func (p *tomlParser) parseValue(key string) error {
+	switch p.peek() {
+	case '"', '\'':
+		s, err := p.parseString()
+		if err != nil {
+			return err
+		}
+		return p.setValue(key, s)
+	case '[':
+		if err := p.defineKey(key); err != nil {
+			return err
+		}
+		return p.parseArray(key)
+	case '{':
+		if err := p.defineKey(key); err != nil {
+			return err
+		}
+		return p.parseInlineTable(key)
+	case 0:
+		if p.eof() {
+			return p.errorf("expected value, found end of file")
+		}
+	}
+
+	// read scalar token
+	st := p.pos
+	for !p.eof() && !strings.ContainsRune(" \t\r\n,]}#", rune(p.s[p.pos])) {
+		p.pos++
+	}
+	tok := p.s[st:p.pos]
+
+	// date and time may be separated by a space
+	if tomlDate.MatchString(tok) && p.pos+1 < len(p.s) && p.s[p.pos] == ' ' && p.s[p.pos+1] >= '0' && p.s[p.pos+1] <= '9' {
+		p.pos++
+		for !p.eof() && !strings.ContainsRune(" \t\r\n,]}#", rune(p.s[p.pos])) {
+			p.pos++
+		}
+		tok = p.s[st:p.pos]
+	}
+
+	value, err := p.scalarOf(tok)
+	if err != nil {
+		return err
+	}
+	return p.setValue(key, value)
+}
+
+// Convert a scalar token to the form readable by the View getters
+func (p *tomlParser) scalarOf(tok string) (string, error) {
+	switch {
+	case tok == "":
+		return "", p.errorf("expected value, found %q", p.peek())
+	case tok == "true" || tok == "false":
+		return tok, nil
+	case tomlDateTime.MatchString(tok):
+		// normalize to RFC 3339
+		return tok[:10] + "T" + strings.ToUpper(tok[11:]), nil
+	case tomlDate.MatchString(tok) || tomlTime.MatchString(tok):
+		return tok, nil
+	}
+
+	// integers are converted to decimal
+	digits := strings.ReplaceAll(tok, "_", "")
+	base := 0
+	switch {
+	case tomlDec.MatchString(tok):
+		base = 10
+	case tomlHex.MatchString(tok):
+		base, digits = 16, digits[2:]
+	case tomlOct.MatchString(tok):
+		base, digits = 8, digits[2:]
+	case tomlBin.MatchString(tok):
+		base, digits = 2, digits[2:]
+	}
+	if base != 0 {
+		v, err := strconv.ParseInt(digits, base, 64)
+		if err != nil {
+			return "", p.errorf("integer %q out of range", tok)
+		}
+		return strconv.FormatInt(v, 10), nil
+	}
+
+	// floats are kept without the underscores
+	if tomlFloat.MatchString(tok) || tomlSpecial.MatchString(tok) {
+		return digits, nil
+	}
+	return "", p.errorf("invalid value %q", tok)
+}
+
+func (p *tomlParser) parseArray(key string) error {
+	p.pos++
+	for i := 0; ; i++ {
+		p.skipWsNl()
+		if p.peek() == ']' {
+			p.pos++
+			return nil
+		}
+		if err := p.parseValue(joinKey(key, strconv.Itoa(i))); err != nil {
+			return err
+		}
+		p.skipWsNl()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+			p.pos++
+			return nil
+		default:
+			return p.errorf("expected ',' or ']' in array")
+		}
+	}
+}
+
+func (p *tomlParser) parseInlineTable(key string) error {
+	p.pos++
+	p.skipWs()
+	if p.peek() == '}' {
+		p.pos++
+		return nil
+	}
+	for {
+		p.skipWs()
+		if err := p.parseKeyValue(key); err != nil {
+			return err
+		}
+		p.skipWs()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			return nil
+		default:
+			return p.errorf("expected ',' or '}' in inline table")
+		}
+	}
+}
+
+func (p *tomlParser) parseString() (string, error) {
+	switch {
+	case strings.HasPrefix(p.s[p.pos:], `"""`):
+		return p.parseMultilineString(`"""`, true)
+	case strings.HasPrefix(p.s[p.pos:], `'''`):
+		return p.parseMultilineString(`'''`, false)
+	}
+
+	// single line string
+	quote := p.s[p.pos]
+	p.pos++
+	var sb strings.Builder
+	for {
+		if p.eof() || p.s[p.pos] == '\n' || p.s[p.pos] == '\r' {
+			return "", p.errorf("unterminated string")
+		}
+		c := p.s[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			return sb.String(), nil
+		case c == '\\' && quote == '"':
+			if err := p.parseEscape(&sb); err != nil {
+				return "", err
+			}
+		default:
+			sb.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+func (p *tomlParser) parseMultilineString(delim string, escapes bool) (string, error) {
+	p.pos += 3
+	p.newline() // a newline right after the delimiter is trimmed
+	var sb strings.Builder
+	for {
+		if p.eof() {
+			return "", p.errorf("unterminated string")
+		}
+		if strings.HasPrefix(p.s[p.pos:], delim) {
+			// up to two additional quotes belong to the content
+			n := 3
+			for n < 5 && p.pos+n < len(p.s) && p.s[p.pos+n] == delim[0] {
+				n++
+			}
+			sb.WriteString(p.s[p.pos+3 : p.pos+n])
+			p.pos += n
+			return sb.String(), nil
+		}
+		c := p.s[p.pos]
+		switch {
+		case c == '\\' && escapes:
+			// line ending backslash trims all whitespaces up to the next content
+			rest := strings.TrimLeft(p.s[p.pos+1:], " \t")
+			if strings.HasPrefix(rest, "\n") || strings.HasPrefix(rest, "\r\n") {
+				p.pos = len(p.s) - len(rest)
+				for {
+					p.skipWs()
+					if !p.newline() {
+						break
+					}
+				}
+				continue
+			}
+			if err := p.parseEscape(&sb); err != nil {
+				return "", err
+			}
+		case p.newline():
+			sb.WriteByte('\n')
+		default:
+			sb.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+func (p *tomlParser) parseEscape(sb *strings.Builder) error {
+	p.pos++
+	if p.eof() {
+		return p.errorf("unterminated string")
+	}
+	c := p.s[p.pos]
+	p.pos++
+	switch c {
+	case 'b':
+		sb.WriteByte('\b')
+	case 't':
+		sb.WriteByte('\t')
+	case 'n':
+		sb.WriteByte('\n')
+	case 'f':
+		sb.WriteByte('\f')
+	case 'r':
+		sb.WriteByte('\r')
+	case '"':
+		sb.WriteByte('"')
+	case '\\':
+		sb.WriteByte('\\')
+	case 'u', 'U':
+		n := 4
+		if c == 'U' {
+			n = 8
+		}
+		if p.pos+n > len(p.s) {
+			return p.errorf("invalid unicode escape")
+		}
+		code, err := strconv.ParseUint(p.s[p.pos:p.pos+n], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(code)) {
+			return p.errorf("invalid unicode escape")
+		}
+		sb.WriteRune(rune(code))
+		p.pos += n
+	default:
+		return p.errorf("invalid escape sequence \\%c", c)
+	}
+	return nil
+}
+
+// Load TOML config from reader and store the values in viewable (must be
+// writable). Tables and dotted keys are mapped to dotted keys, arrays and
+// arrays of tables to indexed keys. Integers are stored in decimal form,
+// floats without digit separators and date-times in RFC 3339 form
+func LoadToml(viewable Viewable, reader io.Reader) error {
+	// check if viewable is writable
+	if !viewable.IsWritable() {
+		return fmt.Errorf("cannot load toml config: %w", ErrReadOnly)
+	}
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	p := tomlParser{
+		s:        string(data),
+		line:     1,
+		viewable: viewable,
+		arrays:   map[string]int{},
+		tables:   map[string]bool{},
+		dotted:   map[string]bool{},
+		keys:     map[string]bool{},
+		parents:  map[string]bool{},
+	}
+	return p.parse()
+}
+
+func tomlQuote(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\b':
+			sb.WriteString(`\b`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\f':
+			sb.WriteString(`\f`)
+		case '\r':
+			sb.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&sb, `\u%04X`, r)
+			} else {
+				sb.WriteRune(r)
+			}
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+func tomlKeyOf(key string) string {
+	if tomlBareKey.MatchString(key) {
+		return key
+	}
+	return tomlQuote(key)
+}
+
+// Format a value, bools and numbers are written bare if they are read back
+// unchanged
+func tomlValueOf(value string) string {
+	switch {
+	case value == "true" || value == "false":
+		return value
+	case tomlDec.MatchString(value) && !strings.ContainsAny(value, "_+"):
+		if v, err := strconv.ParseInt(value, 10, 64); err == nil && strconv.FormatInt(v, 10) == value {
+			return value
+		}
+	case tomlFloat.MatchString(value) && !strings.ContainsAny(value, "_"):
+		if _, err := strconv.ParseFloat(value, 64); err == nil {
+			return value
+		}
+	}
+	return tomlQuote(value)
+}
+
+// Test if the node is an array of scalars
+func isTomlValueList(node *keyTree) bool {
+	if !node.isList() {
+		return false
+	}
+	for _, child := range node.children {
+		if !child.hasValue || len(child.children) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Test if the node is an array of tables
+func isTomlTableList(node *keyTree) bool {
+	if !node.isList() {
+		return false
+	}
+	for _, child := range node.children {
+		if child.hasValue {
+			return false
+		}
+	}
+	return true
+}
+
+func writeTomlHeader(sb *strings.Builder, header []string, array bool) {
+	if sb.Len() > 0 {
+		sb.WriteByte('\n')
+	}
+	if array {
+		fmt.Fprintf(sb, "[[%s]]\n", strings.Join(header, "."))
+	} else {
+		fmt.Fprintf(sb, "[%s]\n", strings.Join(header, "."))
+	}
+}
+
+func writeTomlTable(sb *strings.Builder, node *keyTree, path string, header []string, headerDone bool) error {
+	keys := node.sortedKeys()
+
+	// write direct values first, they belong to the table header
+	for _, k := range keys {
+		child := node.children[k]
+		childpath := joinKey(path, k)
+		if err := child.check(childpath); err != nil {
+			return err
+		}
+		var value string
+		if child.hasValue {
+			value = tomlValueOf(child.value)
+		} else if isTomlValueList(child) {
+			values := make([]string, len(child.children))
+			for i := range values {
+				values[i] = tomlValueOf(child.children[strconv.Itoa(i)].value)
+			}
+			value = "[" + strings.Join(values, ", ") + "]"
+		} else {
+			continue
+		}
+		if !headerDone && len(header) > 0 {
+			writeTomlHeader(sb, header, false)
+		}
+		headerDone = true
+		fmt.Fprintf(sb, "%s = %s\n", tomlKeyOf(k), value)
+	}
+
+	// write subtables
+	for _, k := range keys {
+		child := node.children[k]
+		if child.hasValue || isTomlValueList(child) {
+			continue
+		}
+		childpath := joinKey(path, k)
+		childheader := append(slices.Clone(header), tomlKeyOf(k))
+		if isTomlTableList(child) {
+			for i := 0; i < len(child.children); i++ {
+				idx := strconv.Itoa(i)
+				writeTomlHeader(sb, childheader, true)
+				err := writeTomlTable(sb, child.children[idx], joinKey(childpath, idx), childheader, true)
+				if err != nil {
+					return err
+				}
+			}
+		} else if err := writeTomlTable(sb, child, childpath, childheader, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Serialize viewable to writer in TOML format. Dotted keys are turned into
+// tables and indexed keys into arrays. Bools and decimal numbers are written
+// bare, all other values as strings
+func SaveToml(viewable Viewable, writer io.Writer) error {
+	var sb strings.Builder
+	if err := writeTomlTable(&sb, buildKeyTree(viewable), "", nil, true); err != nil {
+		return fmt.Errorf("toml: %w", err)
+	}
+	_, err := io.WriteString(writer, sb.String())
+	return err
+}
@@ -0,0 +1,230 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadToml(t *testing.T) {
+	buf := bytes.NewBufferString(`
+# root values
+title = "TOML \"example\"\t\u00e9"
+literal = 'C:\path'
+multi = """
+line1 \
+   continued
+line2"""
+rawmulti = '''
+raw\n'''
+
+[http.server]
+port = 8_080
+hex = 0xff
+oct = 0o17
+bin = 0b101
+neg = -42
+ratio = 1_000.5e-3
+inf = -inf
+multiconnections = false
+"quoted key" = 1
+dotted.key = "dk"
+
+[http]
+hosts = [ "a", "b",
+  # comment inside array
+  "c", ]
+nested = [[1, 2], ["x"]]
+point = { x = 1, y.z = 2 }
+
+[dates]
+odt = 1979-05-27 07:32:00z
+ldt = 1979-05-27T07:32:00.999
+ld = 1979-05-27
+lt = 07:32:00
+
+[[fruits]]
+name = "apple"
+
+[fruits.physical]
+color = "red"
+
+[[fruits.varieties]]
+name = "red delicious"
+
+[[fruits.varieties]]
+name = "granny smith"
+
+[[fruits]]
+name = "banana"
+`)
+
+	l := NewLayer("test")
+	err := LoadToml(l, buf)
+	assert.Nil(t, err)
+
+	expected := map[string]string{
+		"title":                        "TOML \"example\"\t\u00e9",
+		"literal":                      `C:\path`,
+		"multi":                        "line1 continued\nline2",
+		"rawmulti":                     `raw\n`,
+		"http.server.port":             "8080",
+		"http.server.hex":              "255",
+		"http.server.oct":              "15",
+		"http.server.bin":              "5",
+		"http.server.neg":              "-42",
+		"http.server.ratio":            "1000.5e-3",
+		"http.server.inf":              "-inf",
+		"http.server.multiconnections": "false",
+		"http.server.quoted key":       "1",
+		"http.server.dotted.key":       "dk",
+		"http.hosts.0":                 "a",
+		"http.hosts.1":                 "b",
+		"http.hosts.2":                 "c",
+		"http.nested.0.0":              "1",
+		"http.nested.0.1":              "2",
+		"http.nested.1.0":              "x",
+		"http.point.x":                 "1",
+		"http.point.y.z":               "2",
+		"dates.odt":                    "1979-05-27T07:32:00Z",
+		"dates.ldt":                    "1979-05-27T07:32:00.999",
+		"dates.ld":                     "1979-05-27",
+		"dates.lt":                     "07:32:00",
+		"fruits.0.name":                "apple",
+		"fruits.0.physical.color":      "red",
+		"fruits.0.varieties.0.name":    "red delicious",
+		"fruits.0.varieties.1.name":    "granny smith",
+		"fruits.1.name":                "banana",
+	}
+	keys := listKeys(l, "", false)
+	assert.Equal(t, len(expected), len(keys))
+	for k, v := range expected {
+		s, ok := l.GetString(k)
+		assert.True(t, ok, k)
+		assert.Equal(t, v, s, k)
+	}
+
+	// typed values are readable through the view
+	v := NewView(l, "http.server")
+	i, ok := v.GetInt("hex")
+	assert.True(t, ok)
+	assert.EqualValues(t, 255, i)
+
+	b, ok := v.GetBool("multiconnections")
+	assert.True(t, ok)
+	assert.False(t, b)
+
+	// try to load toml to a read-only target
+	err = LoadToml(NewEmptyView(), bytes.NewBufferString("a = 1"))
+	assert.ErrorIs(t, err, ErrReadOnly)
+}
+
+func TestLoadTomlErrors(t *testing.T) {
+	tests := map[string]string{
+		"a = 1\nb = ":             "toml: line 2: expected value, found end of file",
+		"a = 1\na = 2":            `toml: line 2: key "a" is already defined`,
+		"[a]\n[a]":                `toml: line 2: table "a" is already defined`,
+		"[[a]]\n[a]":              `toml: line 2: array of tables "a" cannot be redefined as a table`,
+		"a = 1\n[a]":              `toml: line 2: key "a" is already defined`,
+		"[a]\n[a]\nb = 1":         `toml: line 2: table "a" is already defined`,
+		"a = 1\na.b = 2":          `toml: line 2: key "a" is already defined`,
+		"a.b = 1\na = 2":          `toml: line 2: key "a" is already defined`,
+		"a = {x = 1}\n[a]":        `toml: line 2: key "a" is already defined`,
+		"a = {x = 1}\n[a.b]":      `toml: line 2: key "a" is already defined`,
+		"a = []\n[[a]]":           `toml: line 2: key "a" is already defined`,
+		"a.b = 1\n[a]":            `toml: line 2: table "a" is already defined`,
+		"[a.b]\n[a]\nb.c = 1":     `toml: line 3: table "a.b" is already defined`,
+		"[a.b.c]\n[a]\nb = 1":     `toml: line 3: key "a.b" is already defined`,
+		"a = \"abc\nb = 1":        "toml: line 1: unterminated string",
+		"a = 1 b = 2":             `toml: line 1: unexpected character 'b'`,
+		"a = [1, 2":               "toml: line 1: expected ',' or ']' in array",
+		"a = {x = 1 y = 2}":       "toml: line 1: expected ',' or '}' in inline table",
+		"a = 0123":                `toml: line 1: invalid value "0123"`,
+		"a = 9223372036854775808": `toml: line 1: integer "9223372036854775808" out of range`,
+		"a = \"\\q\"":             `toml: line 1: invalid escape sequence \q`,
+		"a = \"\\uZZZZ\"":         "toml: line 1: invalid unicode escape",
+		"\n\n[a\n":                `toml: line 3: expected ']', found '\n'`,
+		"$ = 1":                   `toml: line 1: invalid character '$' in key`,
+		"a 1":                     `toml: line 1: expected '=', found '1'`,
+		"a = '''abc":              "toml: line 1: unterminated string",
+	}
+	for input, msg := range tests {
+		err := LoadToml(NewLayer("test"), bytes.NewBufferString(input))
+		assert.EqualError(t, err, msg, input)
+	}
+
+	// tables may still be extended in the allowed ways
+	valid := []string{
+		"a.b = 1\na.c = 2",
+		"[a.b]\n[a]",
+		"[a]\nb.c = 1\n[a.b.d]",
+		"[[a]]\nb = 1\n[[a]]\nb = 2",
+	}
+	for _, input := range valid {
+		assert.Nil(t, LoadToml(NewLayer("test"), bytes.NewBufferString(input)), input)
+	}
+}
+
+func TestSaveToml(t *testing.T) {
+	l := NewLayer("test")
+	l.SetString("title", "my \"app\"")
+	l.SetString("http.server.port", "8080")
+	l.SetString("http.server.ratio", "0.75")
+	l.SetString("http.server.neg", "-0")
+	l.SetString("http.server.big", "99999999999999999999")
+	l.SetString("http.server.enabled", "true")
+	l.SetString("http.server.hex", "0xff")
+	l.SetString("http.hosts.0", "a")
+	l.SetString("http.hosts.1", "b")
+	l.SetString("fruits.0.name", "apple")
+	l.SetString("fruits.0.varieties.0.name", "red delicious")
+	l.SetString("fruits.1.name", "banana")
+	l.SetString("odd key.x", "1")
+
+	var buf bytes.Buffer
+	err := SaveToml(l, &buf)
+	assert.Nil(t, err)
+	assert.Equal(t, `title = "my \"app\""
+
+[[fruits]]
+name = "apple"
+
+[[fruits.varieties]]
+name = "red delicious"
+
+[[fruits]]
+name = "banana"
+
+[http]
+hosts = ["a", "b"]
+
+[http.server]
+big = "99999999999999999999"
+enabled = true
+hex = "0xff"
+neg = "-0"
+port = 8080
+ratio = 0.75
+
+["odd key"]
+x = 1
+`, buf.String())
+
+	// load it back
+	l2 := NewLayer("test2")
+	err = LoadToml(l2, &buf)
+	assert.Nil(t, err)
+	keys := listKeys(l, "", false)
+	assert.ElementsMatch(t, keys, listKeys(l2, "", false))
+	for _, k := range keys {
+		s1, _ := l.GetString(k)
+		s2, _ := l2.GetString(k)
+		assert.Equal(t, s1, s2)
+	}
+
+	// conflict
+	l.SetString("title.x", "1")
+	err = SaveToml(l, &buf)
+	assert.EqualError(t, err, `toml: key "title" has both a value and subkeys`)
+}
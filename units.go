@@ -0,0 +1,275 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Size in bytes, written with SI (kB, MB, ...) or IEC (KiB, MiB, ...) units
+type ByteSize uint64
+
+// Byte size units
+const (
+	Byte ByteSize = 1
+
+	KB ByteSize = 1000 * Byte
+	MB ByteSize = 1000 * KB
+	GB ByteSize = 1000 * MB
+	TB ByteSize = 1000 * GB
+	PB ByteSize = 1000 * TB
+	EB ByteSize = 1000 * PB
+
+	KiB ByteSize = 1024 * Byte
+	MiB ByteSize = 1024 * KiB
+	GiB ByteSize = 1024 * MiB
+	TiB ByteSize = 1024 * GiB
+	PiB ByteSize = 1024 * TiB
+	EiB ByteSize = 1024 * PiB
+)
+
+var byteSizeUnits = []struct {
+	name string
+	size ByteSize
+}{
+	{"EiB", EiB}, {"EB", EB},
+	{"PiB", PiB}, {"PB", PB},
+	{"TiB", TiB}, {"TB", TB},
+	{"GiB", GiB}, {"GB", GB},
+	{"MiB", MiB}, {"MB", MB},
+	{"KiB", KiB}, {"kB", KB},
+}
+
+// Parse a byte size like "512MiB", "1.5GB" or "4096". Units are case
+// insensitive, the B suffix is optional
+func ParseByteSize(s string) (ByteSize, error) {
+	// split number and unit
+	str := strings.TrimSpace(s)
+	i := strings.IndexFunc(str, func(r rune) bool {
+		return !(r >= '0' && r <= '9') && r != '.'
+	})
+	if i < 0 {
+		i = len(str)
+	}
+	num, unit := str[:i], strings.ToLower(strings.TrimSpace(str[i:]))
+	if num == "" {
+		return 0, fmt.Errorf("invalid byte size %q", s)
+	}
+
+	// find unit
+	mul := Byte
+	if unit != "" && unit != "b" {
+		unit, _ = strings.CutSuffix(unit, "b")
+		found := false
+		for _, u := range byteSizeUnits {
+			name := strings.ToLower(strings.TrimSuffix(u.name, "B"))
+			if unit == name {
+				mul, found = u.size, true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("invalid byte size unit in %q", s)
+		}
+	}
+
+	// integers are converted exactly
+	if n, err := strconv.ParseUint(num, 10, 64); err == nil {
+		if n > math.MaxUint64/uint64(mul) {
+			return 0, fmt.Errorf("byte size out of range: %q", s)
+		}
+		return ByteSize(n) * mul, nil
+	}
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid byte size %q", s)
+	}
+	f *= float64(mul)
+	if f >= math.MaxUint64 {
+		return 0, fmt.Errorf("byte size out of range: %q", s)
+	}
+	return ByteSize(f), nil
+}
+
+// Format the size with the largest unit that divides it exactly, e.g. "512MiB"
+func (b ByteSize) String() string {
+	for _, u := range byteSizeUnits {
+		if b >= u.size && b%u.size == 0 {
+			return strconv.FormatUint(uint64(b/u.size), 10) + u.name
+		}
+	}
+	return strconv.FormatUint(uint64(b), 10) + "B"
+}
+
+// Percentage value, e.g. 75 for "75%"
+type Percent float64
+
+// Parse a percentage like "75%" or "12.5%". A plain number is also accepted as
+// percentage
+func ParsePercent(s string) (Percent, error) {
+	str := strings.TrimSpace(s)
+	str, _ = strings.CutSuffix(str, "%")
+	f, err := strconv.ParseFloat(strings.TrimSpace(str), 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("invalid percentage %q", s)
+	}
+	return Percent(f), nil
+}
+
+// Get the percentage as a fraction, e.g. 0.75 for 75%
+func (p Percent) Fraction() float64 {
+	return float64(p) / 100
+}
+
+// Format the percentage like "75%"
+func (p Percent) String() string {
+	return strconv.FormatFloat(float64(p), 'g', -1, 64) + "%"
+}
+
+// Rate of events per time period, e.g. "100/s" or "5/10m"
+type Rate struct {
+	Count float64       // number of events
+	Per   time.Duration // time period
+}
+
+var rateUnits = map[string]time.Duration{
+	"ns":     time.Nanosecond,
+	"us":     time.Microsecond,
+	"µs":     time.Microsecond,
+	"ms":     time.Millisecond,
+	"s":      time.Second,
+	"sec":    time.Second,
+	"second": time.Second,
+	"m":      time.Minute,
+	"min":    time.Minute,
+	"minute": time.Minute,
+	"h":      time.Hour,
+	"hour":   time.Hour,
+	"d":      24 * time.Hour,
+	"day":    24 * time.Hour,
+}
+
+// Parse a rate like "100/s", "5/min" or "1000/10m". The period is either a
+// unit name or a duration in time.ParseDuration syntax
+func ParseRate(s string) (Rate, error) {
+	num, per, ok := strings.Cut(s, "/")
+	if !ok {
+		return Rate{}, fmt.Errorf("invalid rate %q", s)
+	}
+	count, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+	if err != nil || count < 0 || math.IsNaN(count) || math.IsInf(count, 0) {
+		return Rate{}, fmt.Errorf("invalid rate %q", s)
+	}
+	per = strings.TrimSpace(per)
+	d, ok := rateUnits[strings.ToLower(per)]
+	if !ok {
+		d, err = time.ParseDuration(per)
+		if err != nil || d <= 0 {
+			return Rate{}, fmt.Errorf("invalid rate period in %q", s)
+		}
+	}
+	return Rate{count, d}, nil
+}
+
+// Get the rate as events per second
+func (r Rate) PerSecond() float64 {
+	return r.Count / r.Per.Seconds()
+}
+
+// Format the rate like "100/s" or "5/10m0s"
+func (r Rate) String() string {
+	var per string
+	switch r.Per {
+	case time.Millisecond:
+		per = "ms"
+	case time.Second:
+		per = "s"
+	case time.Minute:
+		per = "m"
+	case time.Hour:
+		per = "h"
+	default:
+		per = r.Per.String()
+	}
+	return strconv.FormatFloat(r.Count, 'g', -1, 64) + "/" + per
+}
+
+// Get byte size value for the given key, see ParseByteSize for the syntax.
+// Reports not found either if value is not convertible to byte size
+func (view View) GetByteSize(key string) (result ByteSize, found bool) {
+	ret, err := view.LookupByteSize(key)
+	return ret, err == nil
+}
+
+// Set byte size value for the given key
+func (view View) SetByteSize(key string, value ByteSize) {
+	view.SetString(key, value.String())
+}
+
+// Get percentage value for the given key, see ParsePercent for the syntax.
+// Reports not found either if value is not convertible to percentage
+func (view View) GetPercent(key string) (result Percent, found bool) {
+	ret, err := view.LookupPercent(key)
+	return ret, err == nil
+}
+
+// Set percentage value for the given key
+func (view View) SetPercent(key string, value Percent) {
+	view.SetString(key, value.String())
+}
+
+// Get rate value for the given key, see ParseRate for the syntax. Reports not
+// found either if value is not convertible to rate
+func (view View) GetRate(key string) (result Rate, found bool) {
+	ret, err := view.LookupRate(key)
+	return ret, err == nil
+}
+
+// Set rate value for the given key
+func (view View) SetRate(key string, value Rate) {
+	view.SetString(key, value.String())
+}
+
+// Parse the byte size from text, implements encoding.TextUnmarshaler
+func (b *ByteSize) UnmarshalText(text []byte) error {
+	v, err := ParseByteSize(string(text))
+	if err == nil {
+		*b = v
+	}
+	return err
+}
+
+// Format the byte size as text, implements encoding.TextMarshaler
+func (b ByteSize) MarshalText() ([]byte, error) {
+	return []byte(b.String()), nil
+}
+
+// Parse the percentage from text, implements encoding.TextUnmarshaler
+func (p *Percent) UnmarshalText(text []byte) error {
+	v, err := ParsePercent(string(text))
+	if err == nil {
+		*p = v
+	}
+	return err
+}
+
+// Format the percentage as text, implements encoding.TextMarshaler
+func (p Percent) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// Parse the rate from text, implements encoding.TextUnmarshaler
+func (r *Rate) UnmarshalText(text []byte) error {
+	v, err := ParseRate(string(text))
+	if err == nil {
+		*r = v
+	}
+	return err
+}
+
+// Format the rate as text, implements encoding.TextMarshaler
+func (r Rate) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
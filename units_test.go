@@ -0,0 +1,180 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseByteSize(t *testing.T) {
+	valid := map[string]ByteSize{
+		"0":                    0,
+		"4096":                 4096,
+		"100B":                 100,
+		"512MiB":               512 * MiB,
+		"512mib":               512 * MiB,
+		"1.5GB":                1500 * MB,
+		"1.5 GiB":              1536 * MiB,
+		" 10k ":                10 * KB,
+		"10KB":                 10 * KB,
+		"2Ki":                  2 * KiB,
+		"18446744073709551615": 18446744073709551615,
+	}
+	for s, exp := range valid {
+		b, err := ParseByteSize(s)
+		assert.Nil(t, err, s)
+		assert.Equal(t, exp, b, s)
+	}
+
+	for _, s := range []string{"", "MiB", "-5", "5XB", "1.2.3MB", "16EiB", "1e30", "18446744073709551616"} {
+		_, err := ParseByteSize(s)
+		assert.NotNil(t, err, s)
+	}
+
+	// canonical form
+	assert.Equal(t, "0B", ByteSize(0).String())
+	assert.Equal(t, "100B", ByteSize(100).String())
+	assert.Equal(t, "512MiB", (512 * MiB).String())
+	assert.Equal(t, "1500MB", (1500 * MB).String())
+	assert.Equal(t, "1000KiB", (1000 * KiB).String())
+	assert.Equal(t, "1025B", ByteSize(1025).String())
+	assert.Equal(t, "15EiB", (15 * EiB).String())
+}
+
+func TestParsePercent(t *testing.T) {
+	p, err := ParsePercent("75%")
+	assert.Nil(t, err)
+	assert.Equal(t, Percent(75), p)
+	assert.Equal(t, 0.75, p.Fraction())
+	assert.Equal(t, "75%", p.String())
+
+	p, err = ParsePercent(" 12.5 % ")
+	assert.Nil(t, err)
+	assert.Equal(t, Percent(12.5), p)
+
+	p, err = ParsePercent("150")
+	assert.Nil(t, err)
+	assert.Equal(t, Percent(150), p)
+
+	for _, s := range []string{"", "%", "abc%", "NaN%", "inf"} {
+		_, err := ParsePercent(s)
+		assert.NotNil(t, err, s)
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	valid := map[string]Rate{
+		"100/s":    {100, time.Second},
+		"5 / min":  {5, time.Minute},
+		"1000/10m": {1000, 10 * time.Minute},
+		"0.5/ms":   {0.5, time.Millisecond},
+		"2/Hour":   {2, time.Hour},
+		"10/1h30m": {10, 90 * time.Minute},
+		"1/day":    {1, 24 * time.Hour},
+	}
+	for s, exp := range valid {
+		r, err := ParseRate(s)
+		assert.Nil(t, err, s)
+		assert.Equal(t, exp, r, s)
+	}
+
+	for _, s := range []string{"", "100", "/s", "x/s", "-1/s", "10/x", "10/0s", "10/-1s"} {
+		_, err := ParseRate(s)
+		assert.NotNil(t, err, s)
+	}
+
+	// canonical form
+	assert.Equal(t, "100/s", Rate{100, time.Second}.String())
+	assert.Equal(t, "5/m", Rate{5, time.Minute}.String())
+	assert.Equal(t, "1000/10m0s", Rate{1000, 10 * time.Minute}.String())
+	assert.Equal(t, 2.0, Rate{120, time.Minute}.PerSecond())
+}
+
+func TestViewUnits(t *testing.T) {
+	l := NewLayer("test")
+	v := NewView(l, "cache")
+	v.SetByteSize("size", 512*MiB)
+	v.SetPercent("ratio", 75)
+	v.SetRate("rate", Rate{100, time.Second})
+	v.SetString("bad", "lots")
+
+	s, ok := l.GetString("cache.size")
+	assert.True(t, ok)
+	assert.Equal(t, "512MiB", s)
+
+	s, ok = l.GetString("cache.ratio")
+	assert.True(t, ok)
+	assert.Equal(t, "75%", s)
+
+	s, ok = l.GetString("cache.rate")
+	assert.True(t, ok)
+	assert.Equal(t, "100/s", s)
+
+	// read back
+	b, found := v.GetByteSize("size")
+	assert.True(t, found)
+	assert.Equal(t, 512*MiB, b)
+
+	p, found := v.GetPercent("ratio")
+	assert.True(t, found)
+	assert.Equal(t, Percent(75), p)
+
+	r, found := v.GetRate("rate")
+	assert.True(t, found)
+	assert.Equal(t, Rate{100, time.Second}, r)
+
+	// malformed values are reported as not found
+	_, found = v.GetByteSize("bad")
+	assert.False(t, found)
+	_, found = v.GetPercent("bad")
+	assert.False(t, found)
+	_, found = v.GetRate("bad")
+	assert.False(t, found)
+
+	// missing and malformed values are distinguished by the lookup getters
+	_, err := v.LookupByteSize("missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = v.LookupByteSize("bad")
+	var cerr *ConversionError
+	assert.ErrorAs(t, err, &cerr)
+	assert.Equal(t, "byte size", cerr.Type)
+
+	_, err = v.LookupPercent("missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = v.LookupPercent("bad")
+	assert.ErrorAs(t, err, &cerr)
+	assert.Equal(t, "percentage", cerr.Type)
+
+	_, err = v.LookupRate("missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = v.LookupRate("bad")
+	assert.ErrorAs(t, err, &cerr)
+	assert.Equal(t, "rate", cerr.Type)
+
+	rr, err := v.LookupRate("rate")
+	assert.Nil(t, err)
+	assert.Equal(t, Rate{100, time.Second}, rr)
+}
+
+func TestUnitsStruct(t *testing.T) {
+	type cache struct {
+		Size  ByteSize `config:"size"`
+		Ratio Percent  `config:"ratio"`
+		Rate  Rate     `config:"rate" default:"10/s"`
+	}
+
+	l := NewLayer("test")
+	err := Marshal(cache{Size: 2 * GiB, Ratio: 50}, NewView(l, ""))
+	assert.Nil(t, err)
+	l.DeleteValue("rate")
+
+	var out cache
+	err = Unmarshal(NewView(l, ""), &out)
+	assert.Nil(t, err)
+	assert.Equal(t, cache{2 * GiB, 50, Rate{10, time.Second}}, out)
+
+	l.SetString("size", "big")
+	err = Unmarshal(NewView(l, ""), &out)
+	assert.NotNil(t, err)
+}
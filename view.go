@@ -1,8 +1,12 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"math"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Key list type where the Viewable.ListKeys function collects keys
@@ -53,6 +57,7 @@ type View struct {
 	prefix   string
 	viewable Viewable
 	writable bool
+	listsep  string
 }
 
 func newViewImpl(viewable Viewable, prefix string, writable bool) View {
@@ -61,16 +66,19 @@ func newViewImpl(viewable Viewable, prefix string, writable bool) View {
 	}
 
 	// test if viewable is a View, then we can refer to its viewable instead
+	listsep := ""
 	view, ok := viewable.(View)
 	if ok {
 		prefix = view.deriveKey(prefix)
 		viewable = view.viewable
+		listsep = view.listsep
 	}
 
 	return View{
 		prefix,
 		viewable,
 		writable,
+		listsep,
 	}
 }
 
@@ -88,10 +96,20 @@ func (v emptyViewable) SetString(key, value string) {
 	panic("empty viewable is not writable")
 }
 
+func (v emptyViewable) TrySetString(key, value string) error {
+	return errEmptyReadOnly
+}
+
 func (v emptyViewable) DeleteValue(key string) {
 	panic("empty viewable is not writable")
 }
 
+func (v emptyViewable) TryDeleteValue(key string) error {
+	return errEmptyReadOnly
+}
+
+var errEmptyReadOnly = fmt.Errorf("%w: empty view", ErrReadOnly)
+
 func (v emptyViewable) ListKeys(prefix string, out *KeyList, direct bool) {}
 
 // Create an empty read-only view
@@ -116,7 +134,16 @@ func (view View) GetString(key string) (value string, found bool) {
 	return view.viewable.GetString(view.deriveKey(key))
 }
 
-// Set raw string value for the given key
+// Optional interface of viewables with error-returning write functions, like
+// Layer, Config and View
+type tryWritable interface {
+	TrySetString(key, value string) error
+	TryDeleteValue(key string) error
+}
+
+var errViewReadOnly = fmt.Errorf("%w: read-only view", ErrReadOnly)
+
+// Set raw string value for the given key, panics if the view is read-only
 func (view View) SetString(key, value string) {
 	if !view.writable {
 		panic("trying to write a read-only view")
@@ -124,17 +151,27 @@ func (view View) SetString(key, value string) {
 	view.viewable.SetString(view.deriveKey(key), value)
 }
 
+// Set raw string value for the given key, returns an error wrapping
+// ErrReadOnly if the view or the wrapped viewable is read-only
+func (view View) TrySetString(key, value string) error {
+	if !view.writable {
+		return errViewReadOnly
+	}
+	if tw, ok := view.viewable.(tryWritable); ok {
+		return tw.TrySetString(view.deriveKey(key), value)
+	}
+	if !view.viewable.IsWritable() {
+		return errViewReadOnly
+	}
+	view.viewable.SetString(view.deriveKey(key), value)
+	return nil
+}
+
 // Get int value for the given key. Reports not found either if value is not
 // convertible to integer
 func (view View) GetInt(key string) (result int64, found bool) {
-	sv, ok := view.GetString(key)
-	if ok {
-		ret, err := strconv.ParseInt(sv, 0, 64)
-		if err == nil {
-			return ret, true
-		}
-	}
-	return 0, false
+	ret, err := view.LookupInt(key)
+	return ret, err == nil
 }
 
 // Set int value for the given key
@@ -145,14 +182,8 @@ func (view View) SetInt(key string, value int64) {
 // Get int value for the given key. Reports not found either if value is not
 // convertible to bool
 func (view View) GetBool(key string) (result bool, found bool) {
-	sv, ok := view.GetString(key)
-	if ok {
-		ret, err := strconv.ParseBool(sv)
-		if err == nil {
-			return ret, true
-		}
-	}
-	return false, false
+	ret, err := view.LookupBool(key)
+	return ret, err == nil
 }
 
 // Set bool value for the given key
@@ -164,7 +195,61 @@ func (view View) SetBool(key string, value bool) {
 	view.SetString(key, sval)
 }
 
-// Delete value for the given key
+// Get float value for the given key. Reports not found either if value is not
+// convertible to float
+func (view View) GetFloat(key string) (result float64, found bool) {
+	ret, err := view.LookupFloat(key)
+	return ret, err == nil
+}
+
+// Set float value for the given key
+func (view View) SetFloat(key string, value float64) {
+	view.SetString(key, strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+// Get unsigned int value for the given key. Reports not found either if value
+// is not convertible to unsigned integer
+func (view View) GetUint(key string) (result uint64, found bool) {
+	ret, err := view.LookupUint(key)
+	return ret, err == nil
+}
+
+// Set unsigned int value for the given key
+func (view View) SetUint(key string, value uint64) {
+	view.SetString(key, strconv.FormatUint(value, 10))
+}
+
+// Parse a duration in time.ParseDuration syntax, or plain seconds as fallback
+func parseDuration(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err == nil {
+		return d, nil
+	}
+	sec, ferr := strconv.ParseFloat(s, 64)
+	if ferr != nil {
+		return 0, err
+	}
+	ns := sec * float64(time.Second)
+	if math.IsNaN(ns) || ns >= math.MaxInt64 || ns <= math.MinInt64 {
+		return 0, errors.New("duration out of range: " + strconv.Quote(s))
+	}
+	return time.Duration(ns), nil
+}
+
+// Get duration value for the given key. The value is either in
+// time.ParseDuration syntax like "1m30s", or plain seconds like "90" or "0.5".
+// Reports not found either if value is not convertible to duration
+func (view View) GetDuration(key string) (result time.Duration, found bool) {
+	ret, err := view.LookupDuration(key)
+	return ret, err == nil
+}
+
+// Set duration value for the given key
+func (view View) SetDuration(key string, value time.Duration) {
+	view.SetString(key, value.String())
+}
+
+// Delete value for the given key, panics if the view is read-only
 func (view View) DeleteValue(key string) {
 	if !view.writable {
 		panic("trying to delete from a read-only view")
@@ -172,6 +257,22 @@ func (view View) DeleteValue(key string) {
 	view.viewable.DeleteValue(view.deriveKey(key))
 }
 
+// Delete value for the given key, returns an error wrapping ErrReadOnly if the
+// view or the wrapped viewable is read-only
+func (view View) TryDeleteValue(key string) error {
+	if !view.writable {
+		return errViewReadOnly
+	}
+	if tw, ok := view.viewable.(tryWritable); ok {
+		return tw.TryDeleteValue(view.deriveKey(key))
+	}
+	if !view.viewable.IsWritable() {
+		return errViewReadOnly
+	}
+	view.viewable.DeleteValue(view.deriveKey(key))
+	return nil
+}
+
 // List keys, see Viewable for details
 func (view View) ListKeys(prefix string, out *KeyList, direct bool) {
 	view.viewable.ListKeys(view.deriveKey(prefix), out, direct)
@@ -190,3 +291,53 @@ func (view View) SubView(prefix string) View {
 func (view View) SubViewReadOnly(prefix string) View {
 	return newViewImpl(view, prefix, false)
 }
+
+// Get int value for the given key of any viewable, see View.GetInt
+func GetInt(viewable Viewable, key string) (int64, bool) {
+	return NewView(viewable, "").GetInt(key)
+}
+
+// Get bool value for the given key of any viewable, see View.GetBool
+func GetBool(viewable Viewable, key string) (bool, bool) {
+	return NewView(viewable, "").GetBool(key)
+}
+
+// Get float value for the given key of any viewable, see View.GetFloat
+func GetFloat(viewable Viewable, key string) (float64, bool) {
+	return NewView(viewable, "").GetFloat(key)
+}
+
+// Get unsigned int value for the given key of any viewable, see View.GetUint
+func GetUint(viewable Viewable, key string) (uint64, bool) {
+	return NewView(viewable, "").GetUint(key)
+}
+
+// Get duration value for the given key of any viewable, see View.GetDuration
+func GetDuration(viewable Viewable, key string) (time.Duration, bool) {
+	return NewView(viewable, "").GetDuration(key)
+}
+
+// Set int value for the given key of any writable viewable
+func SetInt(viewable Viewable, key string, value int64) {
+	NewView(viewable, "").SetInt(key, value)
+}
+
+// Set bool value for the given key of any writable viewable
+func SetBool(viewable Viewable, key string, value bool) {
+	NewView(viewable, "").SetBool(key, value)
+}
+
+// Set float value for the given key of any writable viewable
+func SetFloat(viewable Viewable, key string, value float64) {
+	NewView(viewable, "").SetFloat(key, value)
+}
+
+// Set unsigned int value for the given key of any writable viewable
+func SetUint(viewable Viewable, key string, value uint64) {
+	NewView(viewable, "").SetUint(key, value)
+}
+
+// Set duration value for the given key of any writable viewable
+func SetDuration(viewable Viewable, key string, value time.Duration) {
+	NewView(viewable, "").SetDuration(key, value)
+}
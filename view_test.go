@@ -2,6 +2,7 @@ package config
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -199,3 +200,150 @@ func TestEmptyView(t *testing.T) {
 	view.ListKeys("", &keys, true)
 	assert.Nil(t, keys.v)
 }
+
+func TestViewFloatUintDuration(t *testing.T) {
+	l := NewLayer("test")
+	v := NewView(l, "")
+	v.SetFloat("ratio", 0.75)
+	v.SetUint("big", 18446744073709551615)
+	v.SetDuration("timeout", 90*time.Second)
+	v.SetString("seconds", "2.5")
+	v.SetString("negative", "-1")
+	v.SetString("hex", "0xff")
+	v.SetString("text", "abc")
+	v.SetString("huge", "1e300")
+
+	// check strings
+	s, ok := l.GetString("ratio")
+	assert.True(t, ok)
+	assert.Equal(t, "0.75", s)
+
+	s, ok = l.GetString("big")
+	assert.True(t, ok)
+	assert.Equal(t, "18446744073709551615", s)
+
+	s, ok = l.GetString("timeout")
+	assert.True(t, ok)
+	assert.Equal(t, "1m30s", s)
+
+	// check floats
+	f, ok := v.GetFloat("ratio")
+	assert.True(t, ok)
+	assert.Equal(t, 0.75, f)
+
+	f, ok = v.GetFloat("negative")
+	assert.True(t, ok)
+	assert.Equal(t, -1.0, f)
+
+	_, ok = v.GetFloat("text")
+	assert.False(t, ok)
+
+	_, ok = v.GetFloat("missing")
+	assert.False(t, ok)
+
+	// check unsigned ints
+	u, ok := v.GetUint("big")
+	assert.True(t, ok)
+	assert.EqualValues(t, uint64(18446744073709551615), u)
+
+	u, ok = v.GetUint("hex")
+	assert.True(t, ok)
+	assert.EqualValues(t, 255, u)
+
+	_, ok = v.GetUint("negative")
+	assert.False(t, ok)
+
+	_, ok = v.GetUint("ratio")
+	assert.False(t, ok)
+
+	// check durations
+	d, ok := v.GetDuration("timeout")
+	assert.True(t, ok)
+	assert.Equal(t, 90*time.Second, d)
+
+	d, ok = v.GetDuration("seconds")
+	assert.True(t, ok)
+	assert.Equal(t, 2500*time.Millisecond, d)
+
+	d, ok = v.GetDuration("negative")
+	assert.True(t, ok)
+	assert.Equal(t, -time.Second, d)
+
+	_, ok = v.GetDuration("text")
+	assert.False(t, ok)
+
+	_, ok = v.GetDuration("huge")
+	assert.False(t, ok)
+}
+
+func TestViewableHelpers(t *testing.T) {
+	l := NewLayer("test")
+	SetInt(l, "int", -5)
+	SetBool(l, "bool", true)
+	SetFloat(l, "float", 1.5)
+	SetUint(l, "uint", 7)
+	SetDuration(l, "duration", time.Minute)
+
+	// read through a config
+	conf := NewConfig()
+	conf.AddLayer(l, 0)
+
+	i, ok := GetInt(conf, "int")
+	assert.True(t, ok)
+	assert.EqualValues(t, -5, i)
+
+	b, ok := GetBool(conf, "bool")
+	assert.True(t, ok)
+	assert.True(t, b)
+
+	f, ok := GetFloat(conf, "float")
+	assert.True(t, ok)
+	assert.Equal(t, 1.5, f)
+
+	u, ok := GetUint(conf, "uint")
+	assert.True(t, ok)
+	assert.EqualValues(t, 7, u)
+
+	d, ok := GetDuration(conf, "duration")
+	assert.True(t, ok)
+	assert.Equal(t, time.Minute, d)
+
+	// read-only target
+	assert.Panics(t, func() {
+		SetInt(conf, "int", 1)
+	})
+}
+
+func TestViewTryWrite(t *testing.T) {
+	l := NewLayer("test")
+	view := NewView(l, "a")
+	assert.Nil(t, view.TrySetString("b", "value"))
+	s, _ := l.GetString("a.b")
+	assert.Equal(t, "value", s)
+	assert.Nil(t, view.TryDeleteValue("b"))
+	_, ok := l.GetString("a.b")
+	assert.False(t, ok)
+
+	// read-only view
+	ro := view.SubViewReadOnly("")
+	assert.ErrorIs(t, ro.TrySetString("b", "value"), ErrReadOnly)
+	assert.ErrorIs(t, ro.TryDeleteValue("b"), ErrReadOnly)
+
+	// read-only layer
+	l.LockReadOnly()
+	err := view.TrySetString("b", "value")
+	assert.ErrorIs(t, err, ErrReadOnly)
+	assert.Contains(t, err.Error(), `layer "test"`)
+	assert.ErrorIs(t, view.TryDeleteValue("b"), ErrReadOnly)
+
+	// viewable without error-returning write functions
+	sv := NewView(staticViewable{"key": "value"}, "")
+	assert.ErrorIs(t, sv.TrySetString("key", "other"), ErrReadOnly)
+	assert.ErrorIs(t, sv.TryDeleteValue("key"), ErrReadOnly)
+
+	// empty view
+	empty := NewEmptyView()
+	empty.writable = true
+	assert.ErrorIs(t, empty.TrySetString("key", "value"), ErrReadOnly)
+	assert.ErrorIs(t, empty.TryDeleteValue("key"), ErrReadOnly)
+}
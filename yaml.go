@@ -0,0 +1,176 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+func loadYamlNode(viewable Viewable, key string, node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.MappingNode:
+		// merge keys are processed first, so that explicit keys override them
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			k, v := node.Content[i], node.Content[i+1]
+			if k.Kind == yaml.ScalarNode && k.Tag == "!!merge" {
+				if err := loadYamlMerge(viewable, key, v); err != nil {
+					return err
+				}
+			}
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			k, v := node.Content[i], node.Content[i+1]
+			if k.Kind != yaml.ScalarNode {
+				return fmt.Errorf("yaml: line %d: mapping key must be a scalar", k.Line)
+			}
+			if k.Tag == "!!merge" {
+				continue
+			}
+			if err := loadYamlNode(viewable, joinKey(key, k.Value), v); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		for i, v := range node.Content {
+			if err := loadYamlNode(viewable, joinKey(key, strconv.Itoa(i)), v); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		if node.Tag != "!!null" {
+			viewable.SetString(key, node.Value)
+		}
+	case yaml.AliasNode:
+		return loadYamlNode(viewable, key, node.Alias)
+	default:
+		return fmt.Errorf("yaml: line %d: unexpected node", node.Line)
+	}
+	return nil
+}
+
+func loadYamlMerge(viewable Viewable, key string, node *yaml.Node) error {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	switch node.Kind {
+	case yaml.MappingNode:
+		return loadYamlNode(viewable, key, node)
+	case yaml.SequenceNode:
+		// earlier mappings in the list take precedence
+		for i := len(node.Content) - 1; i >= 0; i-- {
+			if err := loadYamlMerge(viewable, key, node.Content[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("yaml: line %d: merge value must be a mapping", node.Line)
+	}
+}
+
+// Load YAML config from reader and store the values in viewable (must be
+// writable). Nested mappings are mapped to dotted keys, sequences to indexed
+// keys and scalars to their raw string form. If the stream contains multiple
+// documents, they are loaded in order
+func LoadYaml(viewable Viewable, reader io.Reader) error {
+	// check if viewable is writable
+	if !viewable.IsWritable() {
+		return fmt.Errorf("cannot load yaml config: %w", ErrReadOnly)
+	}
+
+	dec := yaml.NewDecoder(reader)
+	for {
+		// decode next document
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(doc.Content) == 0 {
+			continue
+		}
+
+		// root must be a mapping, empty documents are accepted
+		root := doc.Content[0]
+		if root.Kind == yaml.ScalarNode && root.Tag == "!!null" {
+			continue
+		}
+		if root.Kind != yaml.MappingNode {
+			return fmt.Errorf("yaml: line %d: top-level value must be a mapping", root.Line)
+		}
+		if err := loadYamlNode(viewable, "", root); err != nil {
+			return err
+		}
+	}
+}
+
+func yamlScalarOf(value string) *yaml.Node {
+	ret := &yaml.Node{Kind: yaml.ScalarNode, Value: value}
+	switch value {
+	case "", "~", "null", "Null", "NULL":
+		// would be read back as null, force quoting
+		ret.Tag = "!!str"
+	}
+	return ret
+}
+
+func yamlNodeOf(node *keyTree, path string) (*yaml.Node, error) {
+	if err := node.check(path); err != nil {
+		return nil, err
+	}
+	if node.hasValue {
+		return yamlScalarOf(node.value), nil
+	}
+
+	// indexed keys become sequences
+	if node.isList() {
+		ret := &yaml.Node{Kind: yaml.SequenceNode}
+		for i := 0; i < len(node.children); i++ {
+			k := strconv.Itoa(i)
+			child, err := yamlNodeOf(node.children[k], joinKey(path, k))
+			if err != nil {
+				return nil, err
+			}
+			ret.Content = append(ret.Content, child)
+		}
+		return ret, nil
+	}
+
+	// anything else is a mapping
+	return yamlMappingOf(node, path)
+}
+
+func yamlMappingOf(node *keyTree, path string) (*yaml.Node, error) {
+	ret := &yaml.Node{Kind: yaml.MappingNode}
+	for _, k := range node.sortedKeys() {
+		child, err := yamlNodeOf(node.children[k], joinKey(path, k))
+		if err != nil {
+			return nil, err
+		}
+		ret.Content = append(ret.Content, yamlScalarOf(k), child)
+	}
+	return ret, nil
+}
+
+// Serialize viewable to writer in YAML format. Dotted keys are turned into
+// nested mappings and indexed keys into sequences
+func SaveYaml(viewable Viewable, writer io.Writer) error {
+	// root is always a mapping, even if its keys look like indices
+	root, err := yamlMappingOf(buildKeyTree(viewable), "")
+	if err != nil {
+		return fmt.Errorf("yaml: %w", err)
+	}
+
+	enc := yaml.NewEncoder(writer)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		return err
+	}
+	return enc.Close()
+}
@@ -0,0 +1,163 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadYaml(t *testing.T) {
+	buf := bytes.NewBufferString(`
+http:
+  server:
+    port: 8080
+    multiconnections: false
+    servername: MyTestServer v0.9
+  hosts:
+    - a
+    - b
+    - name: c
+nothing: ~
+base: &base
+  user: admin
+  timeout: 10
+derived:
+  <<: *base
+  timeout: 20
+copy: *base
+`)
+
+	l := NewLayer("test")
+	err := LoadYaml(l, buf)
+	assert.Nil(t, err)
+
+	keys := listKeys(l, "", false)
+	assert.ElementsMatch(t, keys, []string{
+		"http.server.port",
+		"http.server.multiconnections",
+		"http.server.servername",
+		"http.hosts.0",
+		"http.hosts.1",
+		"http.hosts.2.name",
+		"base.user",
+		"base.timeout",
+		"derived.user",
+		"derived.timeout",
+		"copy.user",
+		"copy.timeout",
+	})
+
+	// check values through a view
+	v := NewView(l, "http.server")
+	i, ok := v.GetInt("port")
+	assert.True(t, ok)
+	assert.EqualValues(t, 8080, i)
+
+	b, ok := v.GetBool("multiconnections")
+	assert.True(t, ok)
+	assert.False(t, b)
+
+	s, ok := l.GetString("http.hosts.2.name")
+	assert.True(t, ok)
+	assert.Equal(t, "c", s)
+
+	// merged and overridden values
+	s, ok = l.GetString("derived.user")
+	assert.True(t, ok)
+	assert.Equal(t, "admin", s)
+
+	s, ok = l.GetString("derived.timeout")
+	assert.True(t, ok)
+	assert.Equal(t, "20", s)
+
+	// try to load yaml to a read-only target
+	err = LoadYaml(NewEmptyView(), bytes.NewBufferString("a: 1"))
+	assert.ErrorIs(t, err, ErrReadOnly)
+}
+
+func TestLoadYamlDocuments(t *testing.T) {
+	buf := bytes.NewBufferString(`
+a: 1
+b: 2
+---
+---
+b: 3
+`)
+
+	l := NewLayer("test")
+	err := LoadYaml(l, buf)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(listKeys(l, "", false)))
+
+	s, ok := l.GetString("b")
+	assert.True(t, ok)
+	assert.Equal(t, "3", s)
+
+	// empty input
+	err = LoadYaml(l, bytes.NewBufferString(""))
+	assert.Nil(t, err)
+}
+
+func TestLoadYamlErrors(t *testing.T) {
+	l := NewLayer("test")
+
+	err := LoadYaml(l, bytes.NewBufferString("a:\n\tb: 1\n"))
+	assert.ErrorContains(t, err, "yaml: line 2:")
+
+	err = LoadYaml(l, bytes.NewBufferString("\n- 1\n- 2\n"))
+	assert.EqualError(t, err, "yaml: line 2: top-level value must be a mapping")
+
+	err = LoadYaml(l, bytes.NewBufferString("a:\n  ? [x, y]\n  : 1\n"))
+	assert.EqualError(t, err, "yaml: line 2: mapping key must be a scalar")
+
+	err = LoadYaml(l, bytes.NewBufferString("a:\n  <<: 5\n"))
+	assert.EqualError(t, err, "yaml: line 2: merge value must be a mapping")
+}
+
+func TestSaveYaml(t *testing.T) {
+	l := NewLayer("test")
+	l.SetString("http.server.port", "8080")
+	l.SetString("http.server.name", "My Server: v0.9")
+	l.SetString("http.hosts.0", "a")
+	l.SetString("http.hosts.1", "b")
+	l.SetString("http.hosts.2.name", "c")
+	l.SetString("empty", "")
+	l.SetString("null", "null")
+	l.SetString("multi", "line1\nline2")
+
+	var buf bytes.Buffer
+	err := SaveYaml(l, &buf)
+	assert.Nil(t, err)
+	assert.Equal(t, `empty: ""
+http:
+  hosts:
+    - a
+    - b
+    - name: c
+  server:
+    name: 'My Server: v0.9'
+    port: 8080
+multi: |-
+  line1
+  line2
+"null": "null"
+`, buf.String())
+
+	// load it back
+	l2 := NewLayer("test2")
+	err = LoadYaml(l2, &buf)
+	assert.Nil(t, err)
+	keys := listKeys(l, "", false)
+	assert.ElementsMatch(t, keys, listKeys(l2, "", false))
+	for _, k := range keys {
+		s1, _ := l.GetString(k)
+		s2, _ := l2.GetString(k)
+		assert.Equal(t, s1, s2)
+	}
+
+	// conflict
+	l.SetString("http.server.port.x", "1")
+	err = SaveYaml(l, &buf)
+	assert.EqualError(t, err, `yaml: key "http.server.port" has both a value and subkeys`)
+}